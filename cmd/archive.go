@@ -21,10 +21,14 @@ import (
 
 type archiver struct {
 	quiet     bool
+	delete    bool
 	n         int
 	since     string
 	names     []string
+	where     string
+	snapshots map[string]string
 	outFolder string
+	jobs      int
 	data      map[string]*model.Table
 }
 
@@ -33,9 +37,11 @@ var (
 	archiveCmd = &cmds.Command{
 		Use:   "archive",
 		Short: "Archive GitHub repositories according to given criteria.",
-		Long:  `Remove GitHub repositories according to given criteria and archive to a given folder.`,
-		Args:  a.validateArgs,
-		Run:   a.run,
+		Long: `Archive GitHub repositories according to given criteria, i.e. make them read-only
+on GitHub keeping their history, issues, pull requests and wikis (see unarchive).
+With --delete, remove them from GitHub instead and archive them to a given folder.`,
+		Args: a.validateArgs,
+		RunE: a.run,
 	}
 	repos       = model.Repos
 	reposFields = model.Repos.GetFields().(*model.RepositoryFields)
@@ -64,7 +70,7 @@ NOTE: It will be ignored if used with --repos.
 	archiveCmd.Flags().StringP("since",
 		"s",
 		"",
-		`Remove repositories inactive since this date (YYYY-MM-DD).
+		`Archive repositories inactive since this date (YYYY-MM-DD).
 
 * If --since is used together with --n, then the result is:
   "the number --n of repositories to archive --since point in time - whichever comes first."
@@ -84,10 +90,20 @@ NOTE: It will be ignored if used with --repos.
 NOTE: --n will be ignored if used with --repos.
 `)
 
+	archiveCmd.Flags().Bool("delete",
+		false,
+		"Clone the repositories, record their archives to --out and remove them from GitHub\n"+
+			"instead of archiving them on GitHub. Removal is irreversible and loses issues,\n"+
+			"pull requests and wikis.")
+
 	archiveCmd.Flags().StringP("out",
 		"O",
 		".",
-		"Output folder where archives of repositories are recorded.")
+		"Output folder where archives of repositories are recorded with --delete.")
+
+	addJobsFlag(archiveCmd)
+	addWhereFlag(archiveCmd)
+	addSnapshotFlag(archiveCmd)
 
 	rootCmd.AddCommand(archiveCmd)
 }
@@ -103,6 +119,11 @@ func (a *archiver) validateArgs(c *cmds.Command, args []string) error {
 		panic(err)
 	}
 
+	a.delete, err = c.Flags().GetBool("delete")
+	if err != nil {
+		panic(err)
+	}
+
 	// Verify that the number of repos is a positive integer.
 	a.n, err = c.Flags().GetInt("n")
 	if err != nil {
@@ -128,6 +149,10 @@ func (a *archiver) validateArgs(c *cmds.Command, args []string) error {
 			return fmt.Errorf("The date --since does not match format: YYYY-MM-DD " +
 				"(starting from the 1900s)...")
 		}
+		// e.g. 2019-02-30 matches the format, but it's not a date
+		if _, err = model.ParseTime(a.since); err != nil {
+			return fmt.Errorf("The date --since is not valid: %s\n", err.Error())
+		}
 	}
 
 	// Verify that repos are a comma separated list of alphanumerics and
@@ -151,7 +176,17 @@ func (a *archiver) validateArgs(c *cmds.Command, args []string) error {
 		a.n = 0
 	}
 
-	if a.n == 0 && a.since == "" && len(a.names) == 0 {
+	a.where, _, err = validateWhere(c, []model.Entity{model.Repos})
+	if err != nil {
+		return err
+	}
+
+	a.snapshots, err = validateSnapshot(c, []model.Entity{model.Repos})
+	if err != nil {
+		return err
+	}
+
+	if a.n == 0 && a.since == "" && len(a.names) == 0 && a.where == "" {
 		return fmt.Errorf("No criteria for archiving provided. Exiting.")
 	}
 
@@ -164,20 +199,35 @@ func (a *archiver) validateArgs(c *cmds.Command, args []string) error {
 		return err
 	}
 
+	a.jobs, err = validateJobs(c)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
-func (a *archiver) run(c *cmds.Command, args []string) {
-	if gnet.Conf.User == "" || gnet.Conf.Token == "" {
-		fmt.Println("Error! Invalid credentials.")
-		return
+func (a *archiver) run(c *cmds.Command, args []string) error {
+	c.SilenceUsage = true
+	// fail up front if the token lacks scopes of the command
+	scopes := []string{"repo"}
+	if a.delete {
+		// cloning needs credentials
+		if _, _, err := gnet.Credentials(); err != nil {
+			return err
+		}
+		scopes = append(scopes, "delete_repo")
+	}
+	// both archiving and removing are administration of GitHub App
+	permissions := gnet.Permissions{"administration": "write"}
+	if err := checkScopes(a.snapshots, []model.Entity{repos}, permissions, scopes...); err != nil {
+		return err
 	}
 
 	// 0. get cache for repos
-	ca, err := Cache([]model.Entity{repos})
+	ca, err := load(a.snapshots, []model.Entity{repos})
 	if err != nil {
-		fmt.Println("Error!", err.Error())
-		return
+		return err
 	}
 
 	a.addCache(ca)
@@ -187,16 +237,33 @@ func (a *archiver) run(c *cmds.Command, args []string) {
 	if a.names != nil {
 		projection, err = a.dataProjectionByName()
 		if err != nil {
-			fmt.Println(err.Error())
 			if projection == nil {
 				// nothing to work with so just return
-				return
+				return &gnet.Error{Kind: gnet.NotFoundError, Err: err}
 			}
+			fmt.Println(err.Error())
 		}
 	} else {
 		projection = a.data[repos.GetName()]
 	}
 
+	// 2.1 if --where set, filter the projection
+	if a.where != "" {
+		projection, err = projection.Where(a.where)
+		if err != nil {
+			return err
+		}
+	}
+
+	// 2.2 repositories archived on GitHub already are left alone,
+	//     unless they're to be removed
+	if !a.delete {
+		projection, err = projection.Where(reposFields.Archived.Name + " == false")
+		if err != nil {
+			return err
+		}
+	}
+
 	// 1. sort by `last updated` if "last n since" is requested,
 	//    otherwise, keep unsorted, i.e. in order of original
 	//    request from cli, e.g. for
@@ -206,9 +273,10 @@ func (a *archiver) run(c *cmds.Command, args []string) {
 	//       id_repo3 repo3 type_repo3 ...
 	//       id_repo2 repo2 type_repo2 ...
 	if a.n > 0 || a.since != "" {
-		_, err = projection.SortByField(reposFields.Updated.Name)
+		_, err = projection.SortBy(model.SortKey{Field: reposFields.Updated.Name},
+			model.SortKey{Field: reposFields.Name.Name})
 		if err != nil {
-			panic(err)
+			return err
 		}
 	}
 
@@ -216,7 +284,7 @@ func (a *archiver) run(c *cmds.Command, args []string) {
 		// if --n set, get copy of cache with --n least active
 		projection, err = projection.First(a.n)
 		if err != nil {
-			panic(err)
+			return err
 		}
 	}
 
@@ -224,93 +292,175 @@ func (a *archiver) run(c *cmds.Command, args []string) {
 	if a.since != "" {
 		projection, err = projection.LessThanByField(reposFields.Updated.Name, a.since)
 		if err != nil {
-			panic(err)
+			return err
 		}
 	}
 
 	if len(projection.Keys) == 0 {
 		fmt.Println("There are no repositories with requested criteria.Exiting.")
-		return
+		return nil
 	}
 
 	// 4. display the result to the user and request confirmation
-	msg := "\nThe following repositories will be removed from GitHub and archived "
+	msg := "\nThe following repositories will be archived on GitHub (read-only) "
+	if a.delete {
+		msg = "\nThe following repositories will be removed from GitHub and archived "
+	}
 	fmt.Printf(msg+"(%d):\n", len(projection.Keys))
 	fmt.Printf("%s\n", projection)
 
 	if !a.quiet && !utils.GetUserConfirmation() {
-		return
+		return nil
 	}
 
-	// 5. iterate over result to:
-	for _, key := range projection.Keys {
-		//   5.0 git clone from url into -O
-		rawurl := projection.Records[key][reposFields.Url.Index]
-		url, err := utils.Url(rawurl,
-			gnet.Conf.User,
-			gnet.Conf.Token)
-		if err != nil {
-			fmt.Println(err.Error())
-			continue
-		}
-		fmt.Printf("Cloning `%s` to `%s` ...\n", rawurl, a.outFolder)
-		repoName := projection.Records[key][reposFields.Name.Index]
-		err = utils.GitClone(url, a.outFolder, repoName)
-		if err != nil {
-			fmt.Println(err.Error())
-			continue
-		}
+	// 5. process the repositories by --jobs concurrent jobs to:
+	host, err := gnet.WebHost()
+	if err != nil {
+		return err
+	}
 
-		//   5.1 tar.gz the clone in -O
-		clonePath := path.Join(a.outFolder, repoName)
-		fmt.Printf("Creating archive '%s' in '%s'...\n",
-			repoName+".tar.gz", a.outFolder)
-		err = utils.TarGz(repoName, clonePath)
-		if err != nil {
-			fmt.Println(err.Error())
-			continue
+	done := "archived on GitHub"
+	if a.delete {
+		done = "archived and removed"
+	}
+	return runRepos(projection, a.jobs, done, func(key string, l *repoLog) (int64, error) {
+		record := projection.Records[key]
+		if !a.delete {
+			l.Status("archiving on GitHub")
+			return 0, setArchived(record[reposFields.Organization.Index], record[reposFields.Name.Index], true, l)
 		}
+		return a.archive(record, host, l)
+	})
+}
 
-		//   5.2 compare tar -tvf with clone (compare size?)
-		fmt.Printf("Archive '%s' created. Verifying...\n", repoName+".tar.gz")
-		err = utils.TargzVerify(repoName, clonePath)
-		if err != nil {
-			fmt.Println(err.Error())
-			continue
-		}
+// archive records the archive of a clone of the repository of record
+// and removes the repository from GitHub, and returns the size of the
+// archive.
+func (a *archiver) archive(record []string, host string, l *repoLog) (int64, error) {
+	org := record[reposFields.Organization.Index]
+	out, err := outFolderOf(a.outFolder, org)
+	if err != nil {
+		return 0, err
+	}
 
-		//   5.3 rm clone in -O
-		fmt.Printf("Removing %s...\n", clonePath)
-		os.RemoveAll(path.Join(a.outFolder, repoName))
-
-		// 5.4 rm repo in GitHub
-		rmRequest := gnet.MakeGitHubV3Request(http.MethodDelete,
-			path.Join(repos.GetName(),
-				gnet.Conf.Organization,
-				repoName),
-			gnet.Conf.Token)
-		if utils.Debug.DryRun {
-			fmt.Printf("Executing: %s %s \n", rmRequest.Url, rmRequest.Method)
-		} else {
-			resp, status := rmRequest.Execute()
-			if utils.Debug.Verbose {
-				log.Print(resp)
-			}
-			// check response for error:
-			// - `Status: 204 No Content` is OK
-			// - `Status: 403 Forbidden` - abort since Token doesn't have Delete rights
-			// - Any other code, continue
-			if status.Code == http.StatusForbidden {
-				fmt.Println("Error! HttpResponse:", status.Status)
-				fmt.Println("Token is not allowed to delete repository.")
-				return
-			}
-			if status.Code != http.StatusOK && status.Code != http.StatusNoContent {
-				fmt.Println("Error! HttpResponse:", status.Status)
-				continue
-			}
-		} // dry run
-	} // for _, key := range projection.Keys {
+	//   5.0 git clone from url into -O
+	rawurl := record[reposFields.Url.Index]
+	// the access token of GitHub App may be refreshed in long runs
+	user, pass, err := gnet.Credentials()
+	if err != nil {
+		return 0, err
+	}
+	url, err := utils.Url(rawurl, host, user, pass)
+	if err != nil {
+		return 0, err
+	}
+	l.Status("cloning")
+	l.Printf("Cloning `%s` to `%s` ...\n", rawurl, out)
+	repoName := record[reposFields.Name.Index]
+	err = utils.GitClone(url, out, repoName)
+	if err != nil {
+		return 0, err
+	}
+
+	//   5.1 tar.gz the clone in -O
+	clonePath := path.Join(out, repoName)
+	l.Status("archiving")
+	l.Printf("Creating archive '%s' in '%s'...\n",
+		repoName+".tar.gz", out)
+	err = utils.TarGz(repoName, clonePath)
+	if err != nil {
+		return 0, err
+	}
+
+	//   5.2 compare tar -tvf with clone (compare size?)
+	l.Status("verifying")
+	l.Printf("Archive '%s' created. Verifying...\n", repoName+".tar.gz")
+	err = utils.TargzVerify(repoName, clonePath)
+	if err != nil {
+		return 0, err
+	}
+	fi, err := os.Stat(clonePath + ".tar.gz")
+	if err != nil {
+		return 0, err
+	}
+
+	//   5.3 rm clone in -O
+	l.Printf("Removing %s...\n", clonePath)
+	os.RemoveAll(path.Join(out, repoName))
+
+	// 5.4 rm repo in GitHub
+	rmRequest, err := gnet.MakeGitHubV3Request(http.MethodDelete,
+		path.Join(repos.GetName(),
+			org,
+			repoName))
+	if err != nil {
+		return 0, err
+	}
+	if utils.Debug.DryRun {
+		l.Printf("Executing: %s %s \n", rmRequest.Url, rmRequest.Method)
+		return fi.Size(), nil
+	}
+	l.Status("removing")
+	resp, status, err := rmRequest.Execute()
+	if err != nil {
+		return 0, err
+	}
+	if utils.Debug.Verbose {
+		log.Print(resp)
+	}
+	// check response for error:
+	// - `Status: 204 No Content` is OK
+	// - `Status: 403 Forbidden` - abort since Token doesn't have Delete rights
+	// - Any other code, continue
+	if status.Code == http.StatusForbidden {
+		return 0, gnet.Errorf(gnet.AuthError,
+			"HttpResponse: %s. Token is not allowed to delete repository.", status.Status)
+	}
+	if status.Code != http.StatusOK && status.Code != http.StatusNoContent {
+		return 0, fmt.Errorf("HttpResponse: %s", status.Status)
+	}
+
+	return fi.Size(), nil
+}
+
+// setArchived archives the repository of the organization on GitHub,
+// i.e. makes it read-only, or unarchives it:
+//
+//	PATCH /repos/:org/:repo {"archived": true}
+//
+// Messages are reported to l, or printed if l is nil.
+func setArchived(org, name string, archived bool, l *repoLog) error {
+	request, err := gnet.MakeGitHubV3Request(http.MethodPatch,
+		path.Join(repos.GetName(),
+			org,
+			name))
+	if err != nil {
+		return err
+	}
+	request.Query = fmt.Sprintf(`{"archived": %t}`, archived)
+
+	if utils.Debug.DryRun {
+		l.Printf("Executing: %s %s %s\n", request.Url, request.Method, request.Query)
+		return nil
+	}
+	l.Printf("Setting %s/%s archived: %t...\n", org, name, archived)
+	resp, status, err := request.Execute()
+	if err != nil {
+		return err
+	}
+	if utils.Debug.Verbose {
+		log.Print(string(resp))
+	}
+	// - `Status: 200 OK` is OK
+	// - `Status: 403 Forbidden` - token is not an admin of the repository
+	if status.Code == http.StatusForbidden {
+		return gnet.Errorf(gnet.AuthError,
+			"HttpResponse: %s. Token is not allowed to archive or unarchive repository.", status.Status)
+	}
+	if status.Code != http.StatusOK {
+		return gnet.StatusError(status)
+	}
+	return nil
 }
 
 func (a *archiver) dataProjectionByName() (*model.Table, error) {
@@ -9,20 +9,31 @@ import (
 	"fmt"
 	"ghorgs/gnet"
 	"ghorgs/model"
+	"ghorgs/store"
 	"ghorgs/utils"
 	cmds "github.com/spf13/cobra"
 	"os"
 	"path"
+	"path/filepath"
 	"regexp"
 	"strings"
 )
 
+// mirrorsDir is the folder of mirror clones in the state directory
+const mirrorsDir = "mirrors"
+
 type backuper struct {
 	quiet     bool
 	n         int
 	since     string
 	names     []string
+	where     string
+	snapshots map[string]string
 	outFolder string
+	mirrors   string
+	bundle    bool
+	include   []string
+	jobs      int
 	data      map[string]*model.Table
 }
 
@@ -32,9 +43,13 @@ var (
 		Use:   "backup",
 		Short: "Backup GitHub repositories according to given criteria.",
 		Long: "Download GitHub repositories according to given criteria" +
-			" and save a tar.gz file to a given folder.",
+			" and save a tar.gz file (or git bundle) to a given folder.\n" +
+			"Repositories are kept as mirror clones, which are updated by next backups," +
+			" and archived again only if their refs changed.\n" +
+			"Issues, pull requests, releases, wiki, labels and milestones are backed up" +
+			" too with --include.",
 		Args: b.validateArgs,
-		Run:  b.run,
+		RunE: b.run,
 	}
 	backRepos       = model.Repos
 	backReposFields = model.Repos.GetFields().(*model.RepositoryFields)
@@ -88,6 +103,20 @@ NOTE: --n will be ignored if used with --repos.
 		".",
 		"Output folder where archives of repositories are recorded.")
 
+	backupCmd.Flags().String("mirrors",
+		"",
+		"Folder where mirror clones of repositories are kept between backups.\n"+
+			"If empty, `mirrors` in the state directory (see `state_dir` configuration).")
+
+	backupCmd.Flags().Bool("bundle",
+		false,
+		"Record git bundles (<repo>.bundle) instead of tar.gz archives of the mirrors.")
+
+	addIncludeFlag(backupCmd)
+	addJobsFlag(backupCmd)
+	addWhereFlag(backupCmd)
+	addSnapshotFlag(backupCmd)
+
 	rootCmd.AddCommand(backupCmd)
 }
 
@@ -127,6 +156,10 @@ func (b *backuper) validateArgs(c *cmds.Command, args []string) error {
 			return fmt.Errorf("The date --since does not match format: YYYY-MM-DD " +
 				"(starting from the 1900s)...")
 		}
+		// e.g. 2019-02-30 matches the format, but it's not a date
+		if _, err = model.ParseTime(b.since); err != nil {
+			return fmt.Errorf("The date --since is not valid: %s\n", err.Error())
+		}
 	}
 
 	// Verify that repos are a comma separated list of alphanumerics and
@@ -151,7 +184,17 @@ func (b *backuper) validateArgs(c *cmds.Command, args []string) error {
 		b.n = 0
 	}
 
-	if b.n == 0 && b.since == "" && len(b.names) == 0 {
+	b.where, _, err = validateWhere(c, []model.Entity{model.Repos})
+	if err != nil {
+		return err
+	}
+
+	b.snapshots, err = validateSnapshot(c, []model.Entity{model.Repos})
+	if err != nil {
+		return err
+	}
+
+	if b.n == 0 && b.since == "" && len(b.names) == 0 && b.where == "" {
 		return fmt.Errorf("No criteria for archiving provided. Exiting.")
 	}
 
@@ -164,20 +207,46 @@ func (b *backuper) validateArgs(c *cmds.Command, args []string) error {
 		return err
 	}
 
+	b.mirrors, err = c.Flags().GetString("mirrors")
+	if err != nil {
+		panic(err)
+	}
+
+	b.bundle, err = c.Flags().GetBool("bundle")
+	if err != nil {
+		panic(err)
+	}
+
+	b.include, err = validateInclude(c)
+	if err != nil {
+		return err
+	}
+	if b.bundle && len(b.include) > 0 {
+		return fmt.Errorf("--include needs tar.gz archives, it can't be used with --bundle.\n")
+	}
+
+	b.jobs, err = validateJobs(c)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
-func (b *backuper) run(c *cmds.Command, args []string) {
-	if gnet.Conf.User == "" || gnet.Conf.Token == "" {
-		fmt.Println("Error! Invalid credentials.")
-		return
+func (b *backuper) run(c *cmds.Command, args []string) error {
+	c.SilenceUsage = true
+	if _, _, err := gnet.Credentials(); err != nil {
+		return err
+	}
+	// fail up front if the token lacks scopes of the command
+	if err := checkScopes(b.snapshots, []model.Entity{backRepos}, metadataPermissions(b.include), "repo"); err != nil {
+		return err
 	}
 
 	// 0. get cache for repos
-	ca, err := Cache([]model.Entity{backRepos})
+	ca, err := load(b.snapshots, []model.Entity{backRepos})
 	if err != nil {
-		fmt.Println("Error!", err.Error())
-		return
+		return err
 	}
 
 	b.addCache(ca)
@@ -187,16 +256,24 @@ func (b *backuper) run(c *cmds.Command, args []string) {
 	if b.names != nil {
 		projection, err = b.dataProjectionByName()
 		if err != nil {
-			fmt.Println(err.Error())
 			if projection == nil {
 				// nothing to work with so just return
-				return
+				return &gnet.Error{Kind: gnet.NotFoundError, Err: err}
 			}
+			fmt.Println(err.Error())
 		}
 	} else {
 		projection = b.data[backRepos.GetName()]
 	}
 
+	// 2.1 if --where set, filter the projection
+	if b.where != "" {
+		projection, err = projection.Where(b.where)
+		if err != nil {
+			return err
+		}
+	}
+
 	// 1. sort by `last updated` if "last n since" is requested,
 	//    otherwise, keep unsorted, i.e. in order of original
 	//    request from cli, e.g. for
@@ -206,9 +283,10 @@ func (b *backuper) run(c *cmds.Command, args []string) {
 	//       id_repo3 repo3 type_repo3 ...
 	//       id_repo2 repo2 type_repo2 ...
 	if b.n > 0 || b.since != "" {
-		_, err = projection.SortByField(backReposFields.Updated.Name)
+		_, err = projection.SortBy(model.SortKey{Field: backReposFields.Updated.Name},
+			model.SortKey{Field: backReposFields.Name.Name})
 		if err != nil {
-			panic(err)
+			return err
 		}
 	}
 
@@ -216,7 +294,7 @@ func (b *backuper) run(c *cmds.Command, args []string) {
 		// if --n set, get copy of cache with --n most active
 		projection, err = projection.Last(b.n)
 		if err != nil {
-			panic(err)
+			return err
 		}
 	}
 
@@ -224,13 +302,13 @@ func (b *backuper) run(c *cmds.Command, args []string) {
 	if b.since != "" {
 		projection, err = projection.GreaterThanByField(backReposFields.Updated.Name, b.since)
 		if err != nil {
-			panic(err)
+			return err
 		}
 	}
 
 	if len(projection.Keys) == 0 {
 		fmt.Println("There are no repositories with requested criteria. Exiting.")
-		return
+		return nil
 	}
 
 	// 4. display the result to the user and request confirmation
@@ -239,52 +317,139 @@ func (b *backuper) run(c *cmds.Command, args []string) {
 	fmt.Printf("%s\n", projection)
 
 	if !b.quiet && !utils.GetUserConfirmation() {
-		return
+		return nil
 	}
 
-	// 5. iterate over result to:
-	for _, key := range projection.Keys {
-		//   5.0 git clone from url into -O
-		rawurl := projection.Records[key][backReposFields.Url.Index]
-		url, err := utils.Url(rawurl,
-			gnet.Conf.User,
-			gnet.Conf.Token)
-		if err != nil {
-			fmt.Println(err.Error())
-			continue
-		}
-		fmt.Printf("Cloning `%s` to `%s` ...\n", rawurl, b.outFolder)
-		repoName := projection.Records[key][backReposFields.Name.Index]
-		err = utils.GitClone(url, b.outFolder, repoName)
-		if err != nil {
-			fmt.Println(err.Error())
-			continue
-		}
+	// 5. process the repositories by --jobs concurrent jobs to:
+	host, err := gnet.WebHost()
+	if err != nil {
+		return err
+	}
+	mirrors := b.mirrors
+	if mirrors == "" {
+		mirrors = filepath.Join(store.Dir(), mirrorsDir)
+	}
+	// archives are relative to repository name only with absolute path
+	if mirrors, err = filepath.Abs(mirrors); err != nil {
+		return err
+	}
 
-		//   5.1 tar.gz the clone in -O
-		clonePath := path.Join(b.outFolder, repoName)
-		fmt.Printf("Creating archive '%s' in '%s'...\n",
-			repoName+".tar.gz", b.outFolder)
-		err = utils.TarGz(repoName, clonePath)
-		if err != nil {
-			fmt.Println(err.Error())
-			continue
+	return runRepos(projection, b.jobs, "backed up", func(key string, l *repoLog) (int64, error) {
+		return b.backup(projection.Records[key], host, mirrors, l)
+	})
+}
+
+// backup records the archive of the repository of record in its mirror
+// in mirrors, and returns the size of the archive.
+func (b *backuper) backup(record []string, host, mirrors string, l *repoLog) (int64, error) {
+	org := record[backReposFields.Organization.Index]
+	out, err := outFolderOf(b.outFolder, org)
+	if err != nil {
+		return 0, err
+	}
+
+	//   5.0 git clone --mirror from url into --mirrors,
+	//       or update the mirror of previous backup
+	rawurl := record[backReposFields.Url.Index]
+	// the access token of GitHub App may be refreshed in long runs
+	user, pass, err := gnet.Credentials()
+	if err != nil {
+		return 0, err
+	}
+	url, err := utils.Url(rawurl, host, user, pass)
+	if err != nil {
+		return 0, err
+	}
+	repoName := record[backReposFields.Name.Index]
+	// the git data and metadata of the repository
+	dir := filepath.Join(mirrors, org, repoName)
+	mirror := filepath.Join(dir, "git")
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return 0, err
+	}
+	l.Status("mirroring")
+	l.Printf("Mirroring `%s` to `%s` ...\n", rawurl, mirror)
+	changed, err := utils.GitMirror(url, mirror)
+	if err != nil {
+		return 0, err
+	}
+
+	//   5.1 mirror the wiki and write the metadata given by --include
+	if utils.StringInSlice(wikiMeta, b.include) {
+		wiki := filepath.Join(dir, wikiMeta)
+		l.Status("mirroring wiki")
+		l.Printf("Mirroring wiki of `%s` to `%s` ...\n", rawurl, wiki)
+		// repositories without any wiki page have no wiki repository
+		if c, err := utils.GitMirror(strings.TrimSuffix(url, ".git")+".wiki.git", wiki); err != nil {
+			l.Printf("No wiki of `%s` backed up. %s\n", rawurl, err.Error())
+		} else {
+			changed = changed || c
 		}
+	}
+	if len(b.include) > 0 {
+		l.Status("metadata")
+	}
+	c, err := backupMetadata(b.include, org, repoName, dir, l)
+	if err != nil {
+		return 0, err
+	}
+	changed = changed || c
 
-		//   5.2 compare tar -tvf with clone (compare size?)
-		fmt.Printf("Archive '%s' created. Verifying...\n", repoName+".tar.gz")
-		err = utils.TargzVerify(repoName, clonePath)
-		if err != nil {
-			fmt.Println(err.Error())
-			continue
+	//   5.2 tar.gz (or bundle) the mirror in -O, unless it's the same
+	//       as in the archive of previous backup
+	archive := path.Join(out, repoName+".tar.gz")
+	if b.bundle {
+		archive = path.Join(out, repoName+".bundle")
+	}
+	if _, err := os.Stat(archive); err == nil && !changed {
+		l.Printf("Archive '%s' is up to date.\n", archive)
+		return 0, nil
+	}
+
+	if b.bundle {
+		l.Status("bundling")
+		l.Printf("Creating bundle '%s' in '%s'...\n", repoName+".bundle", out)
+		err = utils.GitBundle(mirror, archive)
+	} else {
+		l.Status("archiving")
+		l.Printf("Creating archive '%s' in '%s'...\n", repoName+".tar.gz", out)
+		err = utils.TarGzTo(repoName, dir, archive)
+		if err == nil {
+			//   5.3 compare tar -tvf with the mirror
+			l.Status("verifying")
+			l.Printf("Archive '%s' created. Verifying...\n", repoName+".tar.gz")
+			err = utils.TargzVerifyOf(repoName, dir, archive)
 		}
+	}
+	if err != nil {
+		// don't leave it up to date for next backup
+		os.Remove(archive)
+		return 0, err
+	}
 
-		//   5.3 rm clone in -O
-		fmt.Printf("Removing %s...\n", clonePath)
-		os.RemoveAll(path.Join(b.outFolder, repoName))
-	} // for _, key := range projection.Keys {
+	fi, err := os.Stat(archive)
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
 }
 
 func (b *backuper) dataProjectionByName() (*model.Table, error) {
 	return b.data[backRepos.GetName()].FindAllByFieldValues(backReposFields.Name.Name, b.names)
 }
+
+// outFolderOf returns the folder for repositories of the organization:
+// out for a single organization, or its subfolder named after the
+// organization if more organizations are analyzed, so that repositories
+// with the same name don't clash.
+func outFolderOf(out, org string) (string, error) {
+	if len(gnet.Conf.Organizations) < 2 {
+		return out, nil
+	}
+
+	out = path.Join(out, org)
+	if err := os.MkdirAll(out, 0755); err != nil {
+		return "", err
+	}
+	return out, nil
+}
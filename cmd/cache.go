@@ -6,79 +6,388 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"ghorgs/gnet"
 	"ghorgs/model"
+	"ghorgs/store"
 	"ghorgs/utils"
 	"log"
 	"net/http"
+	"sort"
+	"strings"
+	"sync"
 )
 
 // Cache takes a list of entities represented with Entity interface,
 // queries GitHub for the data for those entities and stores the
 // result of the query in a Table (or returns an error).
-func Cache(request []model.Entity) (map[string]*model.Table, error) {
+// Entities of all the organizations (see gnet.Conf.Organizations) are
+// fetched concurrently by up to `workers` (see configuration) workers
+// and messages are printed in the order of request.
+// Tables of several organizations are merged, with records of
+// organizations in the given order, keyed by `<organization>/<Id>`
+// (e.g. a user is a member of more organizations with the same Id).
+// Each result is saved in the snapshot store and, with --max-age,
+// a recent enough stored snapshot is used instead of querying GitHub.
+// If fields are given, only those fields are queried and the tables
+// contain only those columns, in the given order.
+// Returned errors are of *gnet.Error type, so that the caller can
+// distinguish network, authorization, not found and decode errors.
+func Cache(request []model.Entity, fields ...string) (map[string]*model.Table, error) {
+	orgs := gnet.Conf.Organizations
+	if len(orgs) == 0 {
+		return nil, gnet.Errorf(gnet.ConfigError, "Missing GitHub Organization.")
+	}
+	// with --max-age GitHub may not be queried at all, so the scopes
+	// are checked once the first entity isn't read from the store
+	var scopesOnce sync.Once
+	var scopesErr error
+	check := func() error {
+		scopesOnce.Do(func() {
+			scopesErr = checkScopes(nil, request, nil)
+		})
+		return scopesErr
+	}
+
+	jobs := make([]cacheJob, 0, len(orgs)*len(request))
+	for _, entity := range request {
+		for _, org := range orgs {
+			job := cacheJob{org, entity, entity.GetName()}
+			if len(orgs) > 1 {
+				job.label = org + "/" + job.label
+			}
+			jobs = append(jobs, job)
+		}
+	}
+
+	labels := make([]string, 0, len(jobs))
+	for _, job := range jobs {
+		labels = append(labels, job.label)
+	}
+	fmt.Printf("\nCaching %s...\n", strings.Join(labels, ", "))
+
+	p := makeProgress(labels)
+	results := make([]*cached, len(jobs))
+	queue := make(chan int)
+	workers := gnet.Conf.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(jobs) {
+		workers = len(jobs)
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				// skip the rest after the first failure
+				if p.failed() {
+					results[i] = &cached{err: errCancelled}
+					continue
+				}
+				results[i] = cacheEntity(jobs[i], p, check, fields...)
+				if results[i].err != nil {
+					p.fail()
+				}
+			}
+		}()
+	}
+	for i := range jobs {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+	p.finish()
+
+	for i, res := range results {
+		for _, msg := range res.messages {
+			fmt.Printf("%s: %s\n", labels[i], msg)
+		}
+	}
+	for _, res := range results {
+		if res.err != nil && res.err != errCancelled {
+			return nil, res.err
+		}
+	}
+
 	result := make(map[string]*model.Table, len(request))
+	for i, res := range results {
+		name := jobs[i].entity.GetName()
+		if len(orgs) == 1 {
+			result[name] = res.table
+			continue
+		}
+		merged, ok := result[name]
+		if !ok {
+			merged = model.MakeTable(res.table.Fields)
+			result[name] = merged
+		}
+		for _, key := range res.table.Keys {
+			orgKey := jobs[i].org + "/" + key
+			merged.AddKey(orgKey)
+			merged.AddRecord(orgKey, res.table.Records[key])
+		}
+	}
+
+	return result, nil
+}
+
+// entityScopes lists the scopes of token needed to query the entities.
+var entityScopes = map[string][]string{
+	model.Repos.GetName(): {"read:org", "repo"},
+	model.Users.GetName(): {"read:org"},
+	model.Teams.GetName(): {"read:org"},
+}
+
+// checkScopes checks that the token has the given scopes needed by
+// a command and the ones needed to query the requested entities,
+// unless they're read from snapshots. With GitHub App, the permissions
+// needed by the command are checked too, e.g. administration: write
+// to change repositories, which the scopes don't tell.
+func checkScopes(snapshots map[string]string,
+	request []model.Entity,
+	permissions gnet.Permissions,
+	scopes ...string) error {
 	for _, entity := range request {
-		fmt.Printf("\nCaching %s...", entity.GetName())
+		if _, ok := snapshots[entity.GetName()]; !ok {
+			scopes = append(scopes, entityScopes[entity.GetName()]...)
+		}
+	}
+	if len(scopes) == 0 && len(permissions) == 0 {
+		return nil
+	}
+	return gnet.CheckScopes(scopes, permissions)
+}
 
-		t := entity.MakeTable()
-		req := entity.MakeQuery(gnet.Conf.Organization)
-		if utils.Debug.Verbose {
-			log.Print(req)
+// cacheJob is fetching of a single entity of an organization
+type cacheJob struct {
+	org    string
+	entity model.Entity
+	label  string // shown in progress and messages
+}
+
+var errCancelled = errors.New("cancelled")
+
+// cached is the result of caching a single entity, with the messages
+// to print after all entities are done.
+type cached struct {
+	table    *model.Table
+	messages []string
+	err      error
+}
+
+// cacheEntity fetches all pages of a single entity of an organization
+// (or reads it from a stored snapshot), reporting the progress to p.
+// The scopes of token are checked by check before GitHub is queried.
+func cacheEntity(job cacheJob, p *progress, check func() error, fields ...string) *cached {
+	entity, name := job.entity, job.label
+	res := &cached{}
+
+	if maxAge > 0 {
+		t, s, err := store.Latest(job.org, entity, maxAge, fields...)
+		if err != nil {
+			res.messages = append(res.messages, "Warning! stored snapshots not read: "+err.Error())
 		}
+		if t != nil {
+			p.set(name, "snapshot")
+			res.messages = append(res.messages, "using snapshot "+s.Id())
+			res.table = t
+			return res
+		}
+	}
+	if res.err = check(); res.err != nil {
+		return res
+	}
 
-		gitHubRequest := gnet.MakeGitHubV4Request(req.GetGraphQlJson(), gnet.Conf.Token)
-		resp, status := gitHubRequest.Execute()
-		if status.Code != http.StatusOK {
-			return result, fmt.Errorf("Error! HttpResponse: %s", status.Status)
+	t := entity.MakeTable()
+	if len(fields) > 0 {
+		if t, res.err = t.Project(fields); res.err != nil {
+			return res
 		}
+	}
+	req, err := entity.MakeQuery(job.org, t.Fields)
+	if err != nil {
+		res.err = err
+		return res
+	}
+	if utils.Debug.Verbose {
+		log.Print(req)
+	}
 
-		if utils.Debug.Verbose {
-			log.Print(resp)
+	warnings := make(map[string]int)
+	counter := 0
+	for {
+		page, err := fetchPage(job, req, warnings, p.reporter(name))
+		if err != nil {
+			res.err = err
+			return res
 		}
+		page.AppendTable(t)
 
-		entity.FromJsonBuffer(resp)
-		entity.AppendTable(t)
+		counter += len(page.Nodes)
+		p.set(name, fmt.Sprintf("%d/%d", counter, page.Total))
+		if !page.HasNext {
+			break
+		}
 
-		counter := req.GetCount()
-		if utils.Debug.Verbose {
-			log.Print(entity)
-		} else {
-			if counter <= entity.GetTotal() {
-				fmt.Printf("\n%s: %d/%d", entity.GetName(), counter, entity.GetTotal())
-			} else {
-				fmt.Printf("\n%s: %d/%d", entity.GetName(), entity.GetTotal(), entity.GetTotal())
-			}
+		if p.failed() {
+			res.err = errCancelled
+			return res
+		}
+		if res.err = req.GetNext(page.Cursor); res.err != nil {
+			return res
 		}
+	}
+	res.table = t
 
-		for entity.HasNext() {
-			req.GetNext(entity.GetNext())
+	s, err := store.Save(job.org, entity.GetName(), t)
+	if err != nil {
+		res.messages = append(res.messages, "Warning! snapshot not stored: "+err.Error())
+	} else if utils.Debug.Verbose {
+		log.Printf("%s: stored snapshot %s", name, s.Id())
+	}
 
-			gitHubRequest = gnet.MakeGitHubV4Request(req.GetGraphQlJson(), gnet.Conf.Token)
-			resp, status = gitHubRequest.Execute()
-			if status.Code != http.StatusOK {
-				return result, fmt.Errorf("Error! HttpResponse: %s", status.Status)
-			}
+	msgs := make([]string, 0, len(warnings))
+	for msg := range warnings {
+		msgs = append(msgs, msg)
+	}
+	sort.Strings(msgs)
+	for _, msg := range msgs {
+		res.messages = append(res.messages, fmt.Sprintf("Warning! %s (%d times)", msg, warnings[msg]))
+	}
 
-			entity.FromJsonBuffer(resp)
-			entity.AppendTable(t)
-			if utils.Debug.Verbose {
-				log.Print(entity)
-			} else {
-				counter += req.GetCount()
-				if counter <= entity.GetTotal() {
-					fmt.Printf("\r%s: %d/%d", entity.GetName(), counter, entity.GetTotal())
-				} else {
-					fmt.Printf("\r%s: %d/%d", entity.GetName(), entity.GetTotal(), entity.GetTotal())
-				}
-			}
+	return res
+}
+
+// progress shows the state of all entities being cached on a single
+// line, e.g. `repos: 150/320 | teams: 12/12 | users: ...`, or logs
+// the changes in verbose mode.
+type progress struct {
+	sync.Mutex
+	names   []string
+	status  map[string]string
+	width   int
+	failure bool
+}
+
+func makeProgress(names []string) *progress {
+	return &progress{names: names, status: make(map[string]string)}
+}
+
+func (p *progress) set(name, status string) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.status[name] = status
+	if utils.Debug.Verbose {
+		log.Printf("%s: %s", name, status)
+		return
+	}
+
+	items := make([]string, 0, len(p.names))
+	for _, n := range p.names {
+		s, ok := p.status[n]
+		if !ok {
+			s = "..."
 		}
-		result[entity.GetName()] = t
-		if !utils.Debug.Verbose {
-			fmt.Printf("\n")
+		items = append(items, n+": "+s)
+	}
+	line := strings.Join(items, " | ")
+	// overwrite the rest of a longer previous line
+	pad := p.width - len(line)
+	if pad < 0 {
+		pad = 0
+	}
+	p.width = len(line)
+	fmt.Printf("\r%s%s", line, strings.Repeat(" ", pad))
+}
+
+// reporter returns a function reporting messages of requests
+// of entity name, e.g. waiting for rate limit reset.
+func (p *progress) reporter(name string) func(msg string) {
+	return func(msg string) {
+		p.set(name, msg)
+	}
+}
+
+func (p *progress) fail() {
+	p.Lock()
+	defer p.Unlock()
+	p.failure = true
+}
+
+func (p *progress) failed() bool {
+	p.Lock()
+	defer p.Unlock()
+	return p.failure
+}
+
+// finish ends the progress line
+func (p *progress) finish() {
+	p.Lock()
+	defer p.Unlock()
+	if p.width > 0 && !utils.Debug.Verbose {
+		fmt.Printf("\n")
+	}
+}
+
+// fetchPage executes a query for a single page of job's entity, decodes the
+// response into a page and counts the warnings (partial data errors)
+// reported by GitHub.
+func fetchPage(job cacheJob,
+	q model.Query,
+	warnings map[string]int,
+	progress func(msg string)) (*model.Page, error) {
+	resp, header, err := fetch(q, progress)
+	if err != nil {
+		return nil, err
+	}
+
+	if utils.Debug.Verbose {
+		log.Print(string(resp))
+	}
+
+	page, err := job.entity.ParsePage(job.org, resp)
+	if err != nil {
+		return nil, err
+	}
+
+	ws, err := page.CheckErrors()
+	if err != nil {
+		// GitHub tells where to authorize the token for SAML SSO
+		//   X-GitHub-SSO: required; url=https://github.com/orgs/<org>/sso?authorization_request=...
+		if sso := header.Get("X-GitHub-SSO"); strings.Contains(sso, "url=") {
+			return nil, gnet.Errorf(gnet.AuthError, "%s\nAuthorize the token at: %s",
+				errors.Unwrap(err).Error(), sso[strings.Index(sso, "url=")+len("url="):])
 		}
+		return nil, err
+	}
+	for _, w := range ws {
+		warnings[w.Message]++
 	}
 
-	return result, nil
+	return page, nil
+}
+
+// fetch executes a single GraphQL query and returns the response body
+// and headers.
+func fetch(q model.Query, progress func(msg string)) ([]byte, http.Header, error) {
+	gitHubRequest, err := gnet.MakeGitHubV4Request(q.GetGraphQlJson())
+	if err != nil {
+		return nil, nil, err
+	}
+	gitHubRequest.Progress = progress
+
+	resp, status, err := gitHubRequest.Execute()
+	if err != nil {
+		return nil, nil, err
+	}
+	if status.Code != http.StatusOK {
+		return nil, nil, gnet.StatusError(status)
+	}
+
+	return resp, status.Header, nil
 }
@@ -0,0 +1,168 @@
+//
+// Copyright (c) 2019 Sony Mobile Communications Inc.
+// SPDX-License-Identifier: MIT
+//
+
+package cmd
+
+import (
+	"fmt"
+	"ghorgs/model"
+	"ghorgs/utils"
+	"ghorgs/view"
+	cmds "github.com/spf13/cobra"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type differ struct {
+	entity model.Entity
+	fields []string
+	format string
+	out    string
+	files  []string
+}
+
+var (
+	df      = &differ{}
+	diffCmd = &cmds.Command{
+		Use:   "diff OLD [NEW]",
+		Short: "Compare two snapshots of an entity or a snapshot with live data.",
+		Long: `Compare two snapshots of an entity (files from previous dumps) or a snapshot
+with live data from GitHub if NEW is not given. Records are matched by Id and
+reported as added, removed or changed, with before and after values of changed fields.`,
+		Args: df.validateArgs,
+		RunE: df.run,
+	}
+)
+
+func init() {
+	diffCmd.Flags().StringP("entity",
+		"e",
+		"",
+		"Entity of the snapshots, one of: "+sliceToStr(model.EntityNamesList())+".\n"+
+			"If empty, the entity is given by the name of OLD without extension, e.g. repos.json.")
+
+	diffCmd.Flags().StringP("fields",
+		"f",
+		"",
+		"Comma separated list of fields to compare. If empty, all fields of the snapshots are compared.")
+
+	diffCmd.Flags().StringP("format",
+		"F",
+		view.TextFormat,
+		"Output format, one of: "+sliceToStr(view.DiffFormats)+".")
+
+	diffCmd.Flags().StringP("out",
+		"O",
+		"",
+		"Output file. If empty, the difference is written to standard output.")
+
+	rootCmd.AddCommand(diffCmd)
+}
+
+func (df *differ) validateArgs(c *cmds.Command, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("Give one or two snapshots to compare.\n")
+	}
+	df.files = args
+
+	name, err := c.Flags().GetString("entity")
+	if err != nil {
+		panic(err)
+	}
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(args[0]), filepath.Ext(args[0]))
+	}
+	var ok bool
+	if df.entity, ok = model.EntityMap[name]; !ok {
+		return fmt.Errorf("Unknown entity: %s. Use --entity with one of: %s.\n",
+			name, sliceToStr(model.EntityNamesList()))
+	}
+
+	fields, err := c.Flags().GetString("fields")
+	if err != nil {
+		panic(err)
+	}
+	if fields != "" {
+		df.fields = strings.Split(fields, ",")
+		err = model.ValidateEntityFields(df.fields, []model.Entity{df.entity})
+		if err != nil {
+			return err
+		}
+	}
+
+	df.format, err = c.Flags().GetString("format")
+	if err != nil {
+		panic(err)
+	}
+	if !utils.StringInSlice(df.format, view.DiffFormats) {
+		return fmt.Errorf("Unknown format `%s`. Choose one of: %s.\n",
+			df.format, sliceToStr(view.DiffFormats))
+	}
+
+	df.out, err = c.Flags().GetString("out")
+	if err != nil {
+		panic(err)
+	}
+
+	return nil
+}
+
+func (df *differ) run(c *cmds.Command, args []string) error {
+	c.SilenceUsage = true
+	older, err := df.read(df.files[0])
+	if err != nil {
+		return err
+	}
+
+	var newer *model.Table
+	if len(df.files) == 2 {
+		newer, err = df.read(df.files[1])
+	} else {
+		// query only the fields of the snapshot
+		var ca map[string]*model.Table
+		ca, err = Cache([]model.Entity{df.entity}, older.FieldNames()...)
+		newer = ca[df.entity.GetName()]
+	}
+	if err != nil {
+		return err
+	}
+
+	diff := model.Diff(older, newer)
+	if len(diff.Skipped) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning! Fields only in one of the snapshots are not compared: %s\n",
+			sliceToStr(diff.Skipped))
+	}
+
+	var w io.Writer = os.Stdout
+	if df.out != "" {
+		f, err := os.Create(df.out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	return view.WriteDiff(w, df.format, df.entity.GetName(), diff)
+}
+
+// read reads a snapshot, projected to --fields if given.
+func (df *differ) read(file string) (*model.Table, error) {
+	t, err := view.ReadTable(file, df.entity)
+	if err != nil {
+		return nil, err
+	}
+	if len(df.fields) == 0 {
+		return t, nil
+	}
+
+	t, err = t.Project(df.fields)
+	if err != nil {
+		return nil, fmt.Errorf("Snapshot `%s` is missing a field. %s", file, err.Error())
+	}
+	return t, nil
+}
@@ -8,24 +8,37 @@ package cmd
 import (
 	"fmt"
 	"ghorgs/model"
+	"ghorgs/utils"
 	"ghorgs/view"
 	cmds "github.com/spf13/cobra"
+	"os"
+	"path"
+	"strings"
 )
 
 type dumper struct {
 	entities []model.Entity
-	by       string
-	data     map[string]*model.Table
+	by       []model.SortKey
+	fields   []string
+	format   string
+	options  view.Options
+	split    bool
+	where    string
+	// files of snapshots keyed by entity names
+	snapshots map[string]string
+	// fields used by where, which have to be queried too
+	whereFields []string
+	data        map[string]*model.Table
 }
 
 var d = &dumper{}
 
 var dumpCmd = &cmds.Command{
 	Use:   "dump",
-	Short: "Dumps the requested entities into a csv file.",
-	Long:  `Dumps the requested entities into a csv file.`,
+	Short: "Dumps the requested entities into a csv, tsv, json or ndjson file.",
+	Long:  `Dumps the requested entities into a csv, tsv, json or ndjson file.`,
 	Args:  d.validateArgs,
-	Run:   d.run,
+	RunE:  d.run,
 }
 
 func init() {
@@ -38,9 +51,40 @@ func init() {
 	dumpCmd.Flags().StringP("by",
 		"b",
 		"",
-		"Name of the entity field to use for sorting the result of the dump.\n"+
+		"Comma separated list of entity fields to use for sorting the result of the dump.\n"+
+			"Each field can be followed by `:asc` (default) or `:desc`, e.g. \"DiskUsage (kB):desc,Name\".\n"+
 			"If empty, default sort on GitHub is creation date.")
 
+	dumpCmd.Flags().StringP("fields",
+		"f",
+		"",
+		"Comma separated list of entity fields to dump, in the given order.\n"+
+			"Only these fields are queried from GitHub. `Id` is always dumped as the first column.\n"+
+			"If empty, all fields of the entities are dumped.")
+
+	dumpCmd.Flags().StringP("format",
+		"F",
+		view.CsvFormat,
+		"Output format, one of: "+sliceToStr(view.Formats)+".\n"+
+			"Each entity is dumped into a file named after the entity with the format's extension,\n"+
+			"e.g. repos.json.")
+
+	dumpCmd.Flags().String("delimiter",
+		",",
+		"Delimiter of cells in csv format, a single character (use \\t for tab).")
+
+	dumpCmd.Flags().Bool("bom",
+		false,
+		"Start csv files with UTF-8 byte order mark (helps Excel to detect the encoding).")
+
+	dumpCmd.Flags().Bool("split-organizations",
+		false,
+		"Dump each organization into a folder named after it, e.g. myorg/repos.csv,\n"+
+			"instead of a single file with records of all the organizations.")
+
+	addWhereFlag(dumpCmd)
+	addSnapshotFlag(dumpCmd)
+
 	rootCmd.AddCommand(dumpCmd)
 }
 
@@ -58,40 +102,206 @@ func (d *dumper) validateArgs(c *cmds.Command, args []string) error {
 		return err
 	}
 
-	d.by, err = c.Flags().GetString("by")
+	by, err := c.Flags().GetString("by")
 	if err != nil {
 		panic(err)
 	}
-	err = model.ValidateEntityField(d.by, d.entities)
+	d.by, err = model.ValidateSortKeys(by, d.entities)
+	if err != nil {
+		return err
+	}
+
+	fields, err := c.Flags().GetString("fields")
+	if err != nil {
+		panic(err)
+	}
+	if fields != "" {
+		d.fields = strings.Split(fields, ",")
+		err = model.ValidateEntityFields(d.fields, d.entities)
+		if err != nil {
+			return err
+		}
+
+		// sorting needs the fields in the result
+		for _, key := range d.by {
+			if key.Field != model.ID.Name && !utils.StringInSlice(key.Field, d.fields) {
+				return fmt.Errorf("Field `%s` used with --by is not in --fields.\n", key.Field)
+			}
+		}
+	}
+
+	d.format, err = c.Flags().GetString("format")
+	if err != nil {
+		panic(err)
+	}
+	if !utils.StringInSlice(d.format, view.Formats) {
+		return fmt.Errorf("Unknown format `%s`. Choose one of: %s.\n",
+			d.format, sliceToStr(view.Formats))
+	}
+
+	delimiter, err := c.Flags().GetString("delimiter")
+	if err != nil {
+		panic(err)
+	}
+	if delimiter == `\t` {
+		delimiter = "\t"
+	}
+	runes := []rune(delimiter)
+	if len(runes) != 1 || strings.ContainsAny(delimiter, "\"\r\n") {
+		return fmt.Errorf("--delimiter must be a single character other than quote or new line.\n")
+	}
+	d.options.Delimiter = runes[0]
+
+	d.options.Bom, err = c.Flags().GetBool("bom")
+	if err != nil {
+		panic(err)
+	}
+
+	d.split, err = c.Flags().GetBool("split-organizations")
+	if err != nil {
+		panic(err)
+	}
+
+	d.where, d.whereFields, err = validateWhere(c, d.entities)
+	if err != nil {
+		return err
+	}
+
+	d.snapshots, err = validateSnapshot(c, d.entities)
 	if err != nil {
 		return err
 	}
+	// unless requested, dump only the entities with snapshots
+	if len(d.snapshots) > 0 && !c.Flags().Changed("entities") {
+		entities := make([]model.Entity, 0, len(d.snapshots))
+		for _, entity := range d.entities {
+			if _, ok := d.snapshots[entity.GetName()]; ok {
+				entities = append(entities, entity)
+			}
+		}
+		d.entities = entities
+	}
 
 	return nil
 }
 
-func (d *dumper) run(c *cmds.Command, args []string) {
-	ca, err := Cache(d.entities)
+func (d *dumper) run(c *cmds.Command, args []string) error {
+	c.SilenceUsage = true
+	queryFields := append([]string{}, d.fields...)
+	if len(d.fields) > 0 {
+		whereFields := d.whereFields
+		if d.split {
+			whereFields = append(whereFields, model.OrganizationName)
+		}
+		for _, field := range whereFields {
+			if !utils.StringInSlice(field, queryFields) {
+				queryFields = append(queryFields, field)
+			}
+		}
+	}
+
+	var ca map[string]*model.Table
+	var err error
+	if len(d.snapshots) > 0 {
+		ca, err = Snapshot(d.snapshots, d.entities, queryFields...)
+	} else {
+		ca, err = Cache(d.entities, queryFields...)
+	}
 	if err != nil {
-		fmt.Println("Error!", err.Error())
-		return
+		return err
 	}
 
 	d.addCache(ca)
-	for name, t := range d.data {
-		entity := model.EntityMap[name]
-		filename := entity.GetCsvFile()
-
-		fmt.Printf("\nDumping %s...", filename)
-		if d.by != "" {
-			_, err := t.SortByField(d.by)
+	for _, entity := range d.entities {
+		name := entity.GetName()
+		t := d.data[name]
+		if d.where != "" {
+			t, err = t.Where(d.where)
 			if err != nil {
-				panic(err)
+				return err
+			}
+		}
+
+		if !d.split {
+			if err = d.write(name, t); err != nil {
+				return err
+			}
+			continue
+		}
+
+		orgs, tables, err := splitByOrganization(t)
+		if err != nil {
+			return err
+		}
+		for _, org := range orgs {
+			if err = os.MkdirAll(org, 0755); err != nil {
+				return err
+			}
+			if err = d.write(path.Join(org, name), tables[org]); err != nil {
+				return err
 			}
 		}
-		csv := &view.Csv{FileName: filename, Data: t}
-		csv.Flush()
 	}
+
+	return nil
+}
+
+// write writes the table projected to --fields and sorted --by into
+// a file named basename with the extension of --format.
+func (d *dumper) write(basename string, t *model.Table) error {
+	fmt.Printf("\nDumping %s...", basename+"."+d.format)
+	var err error
+	if len(d.fields) > 0 {
+		t, err = t.Project(d.fields)
+		if err != nil {
+			return err
+		}
+	}
+	if len(d.by) > 0 {
+		_, err := t.SortBy(d.by...)
+		if err != nil {
+			return err
+		}
+	}
+	w, err := view.MakeWriter(d.format, basename, t, d.options)
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// splitByOrganization splits the table to tables of organizations given
+// by the Organization field, in order of their first records. Keys of
+// merged tables of more organizations (see Cache) are turned back to Ids.
+func splitByOrganization(t *model.Table) ([]string, map[string]*model.Table, error) {
+	index := -1
+	for i, field := range t.Fields {
+		if field.Name == model.OrganizationName {
+			index = i
+		}
+	}
+	if index < 0 {
+		return nil, nil, fmt.Errorf("Cannot split %s by organization without field `%s`.\n",
+			sliceToStr(t.FieldNames()), model.OrganizationName)
+	}
+
+	orgs := make([]string, 0)
+	tables := make(map[string]*model.Table)
+	for _, key := range t.Keys {
+		record := t.Records[key]
+		org := record[index]
+		ot, ok := tables[org]
+		if !ok {
+			ot = model.MakeTable(t.Fields)
+			tables[org] = ot
+			orgs = append(orgs, org)
+		}
+		id := strings.TrimPrefix(key, org+"/")
+		ot.AddKey(id)
+		ot.AddRecord(id, record)
+	}
+
+	return orgs, tables, nil
 }
 
 func sliceToStr(sl []string) string {
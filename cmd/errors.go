@@ -0,0 +1,50 @@
+//
+// Copyright (c) 2019 Sony Mobile Communications Inc.
+// SPDX-License-Identifier: MIT
+//
+
+package cmd
+
+import (
+	"errors"
+	"ghorgs/gnet"
+)
+
+// Exit codes of ghorgs, so that scripts can tell apart why
+// a command failed.
+const (
+	ExitOk       = 0
+	ExitError    = 1
+	ExitConfig   = 2
+	ExitNetwork  = 3
+	ExitAuth     = 4
+	ExitNotFound = 5
+	ExitDecode   = 6
+)
+
+// ExitCode returns the exit code matching the kind of a given error.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOk
+	}
+
+	var gerr *gnet.Error
+	if !errors.As(err, &gerr) {
+		return ExitError
+	}
+
+	switch gerr.Kind {
+	case gnet.ConfigError:
+		return ExitConfig
+	case gnet.NetworkError:
+		return ExitNetwork
+	case gnet.AuthError:
+		return ExitAuth
+	case gnet.NotFoundError:
+		return ExitNotFound
+	case gnet.DecodeError:
+		return ExitDecode
+	}
+
+	return ExitError
+}
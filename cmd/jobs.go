@@ -0,0 +1,287 @@
+//
+// Copyright (c) 2019 Sony Mobile Communications Inc.
+// SPDX-License-Identifier: MIT
+//
+
+package cmd
+
+import (
+	"fmt"
+	"ghorgs/gnet"
+	"ghorgs/model"
+	"ghorgs/utils"
+	cmds "github.com/spf13/cobra"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// progressWidth is the maximum length of the progress line, which is
+// overwritten only while it doesn't wrap.
+const progressWidth = 120
+
+func addJobsFlag(c *cmds.Command) {
+	c.Flags().IntP("jobs",
+		"j",
+		1,
+		"Number of repositories processed concurrently.\n"+
+			"With more jobs, output of git is shown only for failures.")
+}
+
+// validateJobs returns the number of jobs given by --jobs.
+func validateJobs(c *cmds.Command) (int, error) {
+	jobs, err := c.Flags().GetInt("jobs")
+	if err != nil {
+		panic(err)
+	}
+	if jobs < 1 {
+		return 0, fmt.Errorf("Insert --jobs greater than 0.\n")
+	}
+	return jobs, nil
+}
+
+// repoTask processes the repository of a key of projection, reporting
+// to l, and returns the number of bytes it recorded, e.g. the size of
+// its archive.
+type repoTask func(key string, l *repoLog) (int64, error)
+
+// runRepos processes the repositories of projection by task in up to
+// jobs concurrent jobs, showing their progress and a summary of errors
+// at the end. With more jobs, messages of a repository are printed
+// together when it's done and output of git only in errors, so that
+// the output doesn't interleave.
+// The rest of repositories is skipped after an authorization error,
+// which is returned, e.g. the token isn't allowed to remove them.
+// Otherwise an error is returned when any repository failed.
+func runRepos(projection *model.Table, jobs int, done string, task repoTask) error {
+	if jobs > len(projection.Keys) {
+		jobs = len(projection.Keys)
+	}
+	p := &repoProgress{
+		total:    len(projection.Keys),
+		start:    time.Now(),
+		buffered: jobs > 1,
+		live:     jobs > 1 && !utils.Debug.Verbose,
+		status:   make(map[string]string),
+	}
+	utils.Git.Quiet = p.buffered
+
+	queue := make(chan string)
+	var wg sync.WaitGroup
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range queue {
+				if p.aborted() {
+					continue
+				}
+				l := p.begin(repoLabel(projection.Records[key]))
+				bytes, err := task(key, l)
+				p.end(l, bytes, err)
+			}
+		}()
+	}
+	for _, key := range projection.Keys {
+		queue <- key
+	}
+	close(queue)
+	wg.Wait()
+
+	return p.summary(done)
+}
+
+// repoLabel returns the name of the repository shown in progress and
+// messages, with its organization if more organizations are analyzed.
+func repoLabel(record []string) string {
+	name := record[reposFields.Name.Index]
+	if len(gnet.Conf.Organizations) > 1 {
+		return record[reposFields.Organization.Index] + "/" + name
+	}
+	return name
+}
+
+// repoLog collects the messages of a repository processed by a job.
+type repoLog struct {
+	label    string
+	p        *repoProgress
+	messages []string
+}
+
+// Printf prints the message at once with a single job (or without
+// any, i.e. nil l), or keeps it to be printed when the repository is done.
+func (l *repoLog) Printf(format string, args ...interface{}) {
+	if l == nil || !l.p.buffered {
+		fmt.Printf(format, args...)
+		return
+	}
+	l.messages = append(l.messages, fmt.Sprintf(format, args...))
+}
+
+// Status shows the step the repository is at in the progress,
+// e.g. `cloning`.
+func (l *repoLog) Status(status string) {
+	l.p.set(l.label, status)
+}
+
+// repoProgress shows the number of processed repositories, recorded
+// bytes, estimated time to finish them and the steps of repositories
+// being processed on a single line, e.g.
+// `12/300 | 1.2 GB | ETA 1h2m0s | repo1: cloning | repo2: verifying`,
+// or logs the steps in verbose mode.
+type repoProgress struct {
+	sync.Mutex
+	total    int
+	done     int
+	bytes    int64
+	start    time.Time
+	buffered bool
+	live     bool
+	width    int
+	running  []string
+	status   map[string]string
+	failures []string
+	abort    error
+}
+
+func (p *repoProgress) begin(label string) *repoLog {
+	p.Lock()
+	p.running = append(p.running, label)
+	p.Unlock()
+	p.set(label, "starting")
+	return &repoLog{label: label, p: p}
+}
+
+func (p *repoProgress) set(label, status string) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.status[label] = status
+	if utils.Debug.Verbose && p.buffered {
+		log.Printf("%s: %s", label, status)
+		return
+	}
+	p.show()
+}
+
+// end prints the messages and result of the repository of l.
+func (p *repoProgress) end(l *repoLog, bytes int64, err error) {
+	p.Lock()
+	defer p.Unlock()
+
+	for i, label := range p.running {
+		if label == l.label {
+			p.running = append(p.running[:i], p.running[i+1:]...)
+			break
+		}
+	}
+	delete(p.status, l.label)
+	p.done++
+	p.bytes += bytes
+
+	p.clear()
+	for _, msg := range l.messages {
+		fmt.Print(msg)
+	}
+	if err != nil {
+		msg := strings.TrimSpace(err.Error())
+		fmt.Printf("[%d/%d] %s: Error! %s\n", p.done, p.total, l.label, msg)
+		// a line per repository in the summary
+		p.failures = append(p.failures, l.label+": "+strings.Join(strings.Fields(msg), " "))
+		if gerr, ok := err.(*gnet.Error); ok && gerr.Kind == gnet.AuthError && p.abort == nil {
+			p.abort = err
+		}
+	} else {
+		fmt.Printf("[%d/%d] %s: done (%s)%s\n", p.done, p.total, l.label, byteSize(bytes), p.eta())
+	}
+	p.show()
+}
+
+func (p *repoProgress) aborted() bool {
+	p.Lock()
+	defer p.Unlock()
+	return p.abort != nil
+}
+
+// show overwrites the progress line.
+func (p *repoProgress) show() {
+	if !p.live {
+		return
+	}
+	items := []string{fmt.Sprintf("%d/%d", p.done, p.total), byteSize(p.bytes)}
+	if eta := p.eta(); eta != "" {
+		items = append(items, strings.TrimPrefix(eta, ", "))
+	}
+	for _, label := range p.running {
+		items = append(items, label+": "+p.status[label])
+	}
+	line := strings.Join(items, " | ")
+	if len(line) > progressWidth {
+		line = line[:progressWidth-3] + "..."
+	}
+	p.clear()
+	p.width = len(line)
+	fmt.Print(line)
+}
+
+// clear clears the progress line, so that messages can be printed.
+func (p *repoProgress) clear() {
+	if p.width > 0 {
+		fmt.Printf("\r%s\r", strings.Repeat(" ", p.width))
+		p.width = 0
+	}
+}
+
+// eta returns the estimated time to process the rest of repositories,
+// e.g. `, ETA 1h2m0s`, or an empty string before any is done.
+func (p *repoProgress) eta() string {
+	if p.done == 0 || p.done == p.total {
+		return ""
+	}
+	left := time.Since(p.start) / time.Duration(p.done) * time.Duration(p.total-p.done)
+	return ", ETA " + left.Round(time.Second).String()
+}
+
+// summary prints the number of processed repositories and their errors,
+// and returns the error which aborted the processing or the number of
+// failed repositories.
+func (p *repoProgress) summary(done string) error {
+	p.Lock()
+	defer p.Unlock()
+
+	p.clear()
+	fmt.Printf("\n%d of %d repositories %s (%s) in %s.\n",
+		p.done-len(p.failures), p.total, done, byteSize(p.bytes),
+		time.Since(p.start).Round(time.Second))
+	if p.done < p.total {
+		fmt.Printf("%d repositories skipped.\n", p.total-p.done)
+	}
+	if len(p.failures) > 0 {
+		fmt.Printf("%d repositories failed:\n", len(p.failures))
+		for _, failure := range p.failures {
+			fmt.Printf("  %s\n", failure)
+		}
+	}
+	if p.abort != nil {
+		return p.abort
+	}
+	if len(p.failures) > 0 {
+		return fmt.Errorf("%d of %d repositories failed.\n", len(p.failures), p.total)
+	}
+	return nil
+}
+
+// byteSize returns the number of bytes in human readable units, e.g. 1.2 GB.
+func byteSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
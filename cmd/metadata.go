@@ -0,0 +1,274 @@
+//
+// Copyright (c) 2019 Sony Mobile Communications Inc.
+// SPDX-License-Identifier: MIT
+//
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"ghorgs/gnet"
+	"ghorgs/utils"
+	cmds "github.com/spf13/cobra"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Metadata of repositories backed up along with their git data by --include.
+const (
+	issuesMeta     = "issues"
+	pullsMeta      = "pulls"
+	releasesMeta   = "releases"
+	wikiMeta       = "wiki"
+	labelsMeta     = "labels"
+	milestonesMeta = "milestones"
+)
+
+var (
+	metadataNames = []string{issuesMeta, pullsMeta, releasesMeta, wikiMeta, labelsMeta, milestonesMeta}
+
+	// metadataLists lists the json files of metadata in the backup of
+	// a repository and the lists of GitHub REST API v3 (relative to
+	// repos/:org/:repo) they're taken from.
+	metadataLists = map[string][]metadataList{
+		issuesMeta: {
+			{"issues.json", "issues?state=all&per_page=100", withoutPulls, nil},
+			// comments of both issues and pull requests
+			{"issue_comments.json", "issues/comments?per_page=100", nil, nil},
+		},
+		pullsMeta: {
+			{"pulls.json", "pulls?state=all&per_page=100", nil, backupReviews},
+			// comments of reviews on the code
+			{"pull_comments.json", "pulls/comments?per_page=100", nil, nil},
+		},
+		releasesMeta:   {{"releases.json", "releases?per_page=100", nil, backupAssets}},
+		labelsMeta:     {{"labels.json", "labels?per_page=100", nil, nil}},
+		milestonesMeta: {{"milestones.json", "milestones?state=all&per_page=100", nil, nil}},
+	}
+)
+
+// metadataPermission lists the permissions of GitHub App needed to read
+// the metadata, other than contents (releases and wiki).
+var metadataPermission = map[string]string{
+	issuesMeta:     "issues",
+	pullsMeta:      "pull_requests",
+	labelsMeta:     "issues",
+	milestonesMeta: "issues",
+}
+
+const (
+	// file of reviews of all pull requests
+	pullReviewsFile = "pull_reviews.json"
+	// folder of assets of releases, in subfolders named by ids of releases
+	releasesDir = "releases"
+)
+
+// metadataList is a list of GitHub REST API v3 written to a json file,
+// optionally filtered, and followed by backup of details of its items.
+type metadataList struct {
+	file    string
+	query   string
+	filter  func(items []json.RawMessage) []json.RawMessage
+	details func(query, dir string, items []json.RawMessage, l *repoLog) (bool, error)
+}
+
+func addIncludeFlag(c *cmds.Command) {
+	c.Flags().String("include",
+		"",
+		"Comma separated list of metadata of repositories backed up along with git data, any of:\n"+
+			"    "+sliceToStr(metadataNames)+".")
+}
+
+// validateInclude returns metadata given by --include.
+func validateInclude(c *cmds.Command) ([]string, error) {
+	include, err := c.Flags().GetString("include")
+	if err != nil {
+		panic(err)
+	}
+	if include == "" {
+		return nil, nil
+	}
+
+	result := strings.Split(include, ",")
+	for _, name := range result {
+		if !utils.StringInSlice(name, metadataNames) {
+			return nil, fmt.Errorf("Unknown metadata `%s` in --include. Choose any of: %s.\n",
+				name, sliceToStr(metadataNames))
+		}
+	}
+	return result, nil
+}
+
+// metadataPermissions returns the permissions of GitHub App needed
+// to read the metadata given by include.
+func metadataPermissions(include []string) gnet.Permissions {
+	permissions := make(gnet.Permissions)
+	for _, name := range include {
+		if p, ok := metadataPermission[name]; ok {
+			permissions[p] = "read"
+		}
+	}
+	return permissions
+}
+
+// backupMetadata writes the metadata of the repository of the organization
+// given by include (except wiki, which is a git repository) into dir,
+// reporting to l, and tells whether any of it changed since the previous
+// backup.
+func backupMetadata(include []string, org, repo, dir string, l *repoLog) (bool, error) {
+	changed := false
+	query := path.Join("repos", org, repo)
+	for _, name := range include {
+		for _, list := range metadataLists[name] {
+			items, err := gnet.GetPages(query + "/" + list.query)
+			if err != nil {
+				return changed, fmt.Errorf("Could not get %s of %s/%s. %s", name, org, repo, err.Error())
+			}
+			if list.filter != nil {
+				items = list.filter(items)
+			}
+			l.Printf("Writing %d %s of %s/%s...\n", len(items), strings.TrimSuffix(list.file, ".json"), org, repo)
+			c, err := writeJson(filepath.Join(dir, list.file), items)
+			if err != nil {
+				return changed, err
+			}
+			changed = changed || c
+
+			if list.details != nil {
+				c, err = list.details(query, dir, items, l)
+				if err != nil {
+					return changed, err
+				}
+				changed = changed || c
+			}
+		}
+	}
+
+	return changed, nil
+}
+
+// backupReviews writes reviews of the pull requests into one file.
+func backupReviews(query, dir string, pulls []json.RawMessage, l *repoLog) (bool, error) {
+	reviews := make([]json.RawMessage, 0)
+	for _, item := range pulls {
+		var pull struct {
+			Number int `json:"number"`
+		}
+		if err := json.Unmarshal(item, &pull); err != nil {
+			return false, &gnet.Error{Kind: gnet.DecodeError, Err: err}
+		}
+		items, err := gnet.GetPages(fmt.Sprintf("%s/pulls/%d/reviews?per_page=100", query, pull.Number))
+		if err != nil {
+			return false, fmt.Errorf("Could not get reviews of %s/pulls/%d. %s", query, pull.Number, err.Error())
+		}
+		reviews = append(reviews, items...)
+	}
+
+	return writeJson(filepath.Join(dir, pullReviewsFile), reviews)
+}
+
+// backupAssets downloads assets of the releases into releases/<release id>/,
+// skipping the ones downloaded by previous backups.
+func backupAssets(query, dir string, releases []json.RawMessage, l *repoLog) (bool, error) {
+	changed := false
+	for _, item := range releases {
+		var release struct {
+			Id     int64 `json:"id"`
+			Assets []struct {
+				Id   int64  `json:"id"`
+				Name string `json:"name"`
+				Size int64  `json:"size"`
+			} `json:"assets"`
+		}
+		if err := json.Unmarshal(item, &release); err != nil {
+			return changed, &gnet.Error{Kind: gnet.DecodeError, Err: err}
+		}
+
+		for _, asset := range release.Assets {
+			name := filepath.Base(asset.Name)
+			if name == "." || name == ".." || name == string(filepath.Separator) {
+				return changed, fmt.Errorf("Invalid name of asset `%s` of %s.\n", asset.Name, query)
+			}
+			file := filepath.Join(dir, releasesDir, fmt.Sprintf("%d", release.Id), name)
+			if fi, err := os.Stat(file); err == nil && fi.Size() == asset.Size {
+				continue
+			}
+
+			// GET /repos/:org/:repo/releases/assets/:asset_id
+			request, err := gnet.MakeGitHubV3Request(http.MethodGet,
+				fmt.Sprintf("%s/releases/assets/%d", query, asset.Id))
+			if err != nil {
+				return changed, err
+			}
+			request.Headers["Accept"] = "application/octet-stream"
+			l.Printf("Downloading asset `%s`...\n", asset.Name)
+			if err = downloadFile(request, file); err != nil {
+				return changed, err
+			}
+			changed = true
+		}
+	}
+
+	return changed, nil
+}
+
+// downloadFile writes the response of request into file via a temporary
+// file next to it, so that a failed download doesn't leave a partial file.
+func downloadFile(request *gnet.Request, file string) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(file), "."+filepath.Base(file)+".part-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	status, err := request.Download(tmp)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if status.Code != http.StatusOK {
+		return gnet.StatusError(status)
+	}
+	if err = os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// writeJson writes the items into file as an indented json array, unless
+// the file has the same content already, and tells whether it was written.
+func writeJson(file string, items []json.RawMessage) (bool, error) {
+	buff, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		return false, &gnet.Error{Kind: gnet.DecodeError, Err: err}
+	}
+	if old, err := ioutil.ReadFile(file); err == nil && bytes.Equal(old, buff) {
+		return false, nil
+	}
+	return true, ioutil.WriteFile(file, buff, 0644)
+}
+
+// withoutPulls filters out pull requests from issues (which include them).
+func withoutPulls(items []json.RawMessage) []json.RawMessage {
+	result := make([]json.RawMessage, 0, len(items))
+	for _, item := range items {
+		var issue struct {
+			PullRequest *json.RawMessage `json:"pull_request"`
+		}
+		if err := json.Unmarshal(item, &issue); err == nil && issue.PullRequest == nil {
+			result = append(result, item)
+		}
+	}
+	return result
+}
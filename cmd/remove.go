@@ -19,12 +19,14 @@ import (
 )
 
 type remover struct {
-	quiet   bool
-	mfa     bool
-	company bool
-	access  bool
-	names   []string
-	data    map[string]*model.Table
+	quiet     bool
+	mfa       bool
+	company   bool
+	access    bool
+	names     []string
+	where     string
+	snapshots map[string]string
+	data      map[string]*model.Table
 }
 
 var (
@@ -34,7 +36,7 @@ var (
 		Short: "Remove GitHub users according to given criteria.",
 		Long:  `Remove GitHub users according to given criteria.`,
 		Args:  r.validateArgs,
-		Run:   r.run,
+		RunE:  r.run,
 	}
 	users       = model.Users
 	usersFields = model.Users.GetFields().(*model.UsersFields)
@@ -68,6 +70,9 @@ func init() {
 		"Comma separated list of users to remove. "+
 			"Name can contain alphanumeric and special characters '_', '.' and '-'.")
 
+	addWhereFlag(removeCmd)
+	addSnapshotFlag(removeCmd)
+
 	rootCmd.AddCommand(removeCmd)
 }
 
@@ -82,7 +87,7 @@ func (r *remover) validateArgs(c *cmds.Command, args []string) error {
 		panic(err)
 	}
 
-	r.mfa, err = c.Flags().GetBool("2FA")
+	r.mfa, err = c.Flags().GetBool("MFA")
 	if err != nil {
 		panic(err)
 	}
@@ -120,20 +125,34 @@ func (r *remover) validateArgs(c *cmds.Command, args []string) error {
 		r.access = false
 	}
 
+	// --where is combined with other criteria with AND operation.
+	r.where, _, err = validateWhere(c, []model.Entity{model.Users})
+	if err != nil {
+		return err
+	}
+
+	r.snapshots, err = validateSnapshot(c, []model.Entity{model.Users})
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
-func (r *remover) run(c *cmds.Command, args []string) {
-	if gnet.Conf.User == "" || gnet.Conf.Token == "" {
-		fmt.Println("Error! Invalid credentials.")
-		return
+func (r *remover) run(c *cmds.Command, args []string) error {
+	c.SilenceUsage = true
+	if _, _, err := gnet.Credentials(); err != nil {
+		return err
+	}
+	// fail up front if the token lacks scopes of the command
+	if err := checkScopes(r.snapshots, []model.Entity{users}, nil, "admin:org"); err != nil {
+		return err
 	}
 
 	// 0. get cache for users
-	ca, err := Cache([]model.Entity{users})
+	ca, err := load(r.snapshots, []model.Entity{users})
 	if err != nil {
-		fmt.Println("Error!", err.Error())
-		return
+		return err
 	}
 
 	r.addCache(ca)
@@ -143,16 +162,24 @@ func (r *remover) run(c *cmds.Command, args []string) {
 	if r.names != nil {
 		projection, err = r.dataProjectionByName()
 		if err != nil {
-			fmt.Println(err.Error())
 			if projection == nil {
 				// nothing to work with so just return
-				return
+				return &gnet.Error{Kind: gnet.NotFoundError, Err: err}
 			}
+			fmt.Println(err.Error())
 		}
 	} else {
 		projection = r.data[users.GetName()]
 	}
 
+	// 2.1 if --where set, filter the projection
+	if r.where != "" {
+		projection, err = projection.Where(r.where)
+		if err != nil {
+			return err
+		}
+	}
+
 	// 2FA, Company affiliation and Accessible repositories
 	// criteria are combined with AND operation.
 	// (Note: if r.names == true,
@@ -167,7 +194,7 @@ func (r *remover) run(c *cmds.Command, args []string) {
 		}
 		if tmp == nil {
 			// nothing to work with so return here
-			return
+			return nil
 		}
 
 		projection = tmp
@@ -182,7 +209,7 @@ func (r *remover) run(c *cmds.Command, args []string) {
 		}
 		if tmp == nil {
 			// nothing to work with so return here
-			return
+			return nil
 		}
 
 		projection = tmp
@@ -197,7 +224,7 @@ func (r *remover) run(c *cmds.Command, args []string) {
 		}
 		if tmp == nil {
 			// nothing to work with so return here
-			return
+			return nil
 		}
 
 		projection = tmp
@@ -205,7 +232,12 @@ func (r *remover) run(c *cmds.Command, args []string) {
 
 	if projection == nil {
 		// nothing to work with so just return
-		return
+		return nil
+	}
+
+	if len(projection.Keys) == 0 {
+		fmt.Println("There are no users with requested criteria. Exiting.")
+		return nil
 	}
 
 	// 4. display the result to the user and request confirmation
@@ -214,24 +246,31 @@ func (r *remover) run(c *cmds.Command, args []string) {
 	fmt.Printf("%s\n", projection)
 
 	if !r.quiet && !utils.GetUserConfirmation() {
-		return
+		return nil
 	}
 
 	// 5. iterate over the result to remove the users
 	for _, key := range projection.Keys {
 		userLogin := projection.Records[key][usersFields.Login.Index]
+		org := projection.Records[key][usersFields.Organization.Index]
 		// create GitHub v3 request to delete a user:
 		//     DELETE /orgs/:org/members/:username
-		rmRequest := gnet.MakeGitHubV3Request(http.MethodDelete,
+		rmRequest, err := gnet.MakeGitHubV3Request(http.MethodDelete,
 			path.Join("orgs",
-				gnet.Conf.Organization,
+				org,
 				"members",
-				userLogin),
-			gnet.Conf.Token)
+				userLogin))
+		if err != nil {
+			return err
+		}
 		if utils.Debug.DryRun {
 			fmt.Printf("Executing %s %s\n", rmRequest.Url, rmRequest.Method)
 		} else {
-			resp, status := rmRequest.Execute()
+			resp, status, err := rmRequest.Execute()
+			if err != nil {
+				fmt.Println("Error!", err.Error())
+				continue
+			}
 			if utils.Debug.Verbose {
 				log.Print(resp)
 			}
@@ -240,9 +279,8 @@ func (r *remover) run(c *cmds.Command, args []string) {
 			// - `Status: 403 Forbidden` - abort since Token doesn't have Delete rights
 			// - Any other code, continue
 			if status.Code == http.StatusForbidden {
-				fmt.Println("Error! HttpResponse:", status.Status)
-				fmt.Println("Token is not allowed to delete repository.")
-				return
+				return gnet.Errorf(gnet.AuthError,
+					"HttpResponse: %s. Token is not allowed to remove user.", status.Status)
 			}
 			if status.Code != http.StatusOK && status.Code != http.StatusNoContent {
 				fmt.Println("Error! HttpResponse:", status.Status)
@@ -250,6 +288,8 @@ func (r *remover) run(c *cmds.Command, args []string) {
 			}
 		}
 	}
+
+	return nil
 }
 
 func (r *remover) dataProjectionByName() (*model.Table, error) {
@@ -0,0 +1,303 @@
+//
+// Copyright (c) 2019 Sony Mobile Communications Inc.
+// SPDX-License-Identifier: MIT
+//
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"ghorgs/gnet"
+	"ghorgs/utils"
+	cmds "github.com/spf13/cobra"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	remoteBranches = "refs/remotes/origin/"
+	localBranches  = "refs/heads/"
+)
+
+type restorer struct {
+	archive string
+	name    string
+	private bool
+}
+
+var (
+	rs         = &restorer{}
+	restoreCmd = &cmds.Command{
+		Use:   "restore",
+		Short: "Restore a GitHub repository from an archive created by archive or backup.",
+		Long: `Restore a GitHub repository from a tar.gz archive created by archive --delete
+or backup: create the repository in the organization and push all its branches
+and tags back.`,
+		Args: rs.validateArgs,
+		RunE: rs.run,
+	}
+)
+
+func init() {
+	restoreCmd.Flags().StringP("archive",
+		"a",
+		"",
+		"The tar.gz archive of the repository, e.g. myrepo.tar.gz.")
+
+	restoreCmd.Flags().String("name",
+		"",
+		"Name of the restored repository. If empty, the name of the archive without .tar.gz.")
+
+	restoreCmd.Flags().Bool("private",
+		false,
+		"Create the restored repository as private.")
+
+	rootCmd.AddCommand(restoreCmd)
+}
+
+func (rs *restorer) validateArgs(c *cmds.Command, args []string) error {
+	var err error
+	rs.archive, err = c.Flags().GetString("archive")
+	if err != nil {
+		panic(err)
+	}
+	if rs.archive == "" {
+		return fmt.Errorf("Give the archive of the repository with --archive.\n")
+	}
+	if _, err := os.Stat(rs.archive); err != nil {
+		return err
+	}
+
+	rs.name, err = c.Flags().GetString("name")
+	if err != nil {
+		panic(err)
+	}
+	if rs.name == "" {
+		rs.name = strings.TrimSuffix(filepath.Base(rs.archive), ".tar.gz")
+	}
+	matched, err := regexp.MatchString(`^[\.|\-|\_|[:alnum:]]+$`, rs.name)
+	if err != nil {
+		return err
+	}
+	if !matched {
+		return fmt.Errorf("Repository name `%s` can only be written in ascii alpha-numeric "+
+			"characters ([._-] are allowed.). Use --name.\n", rs.name)
+	}
+
+	rs.private, err = c.Flags().GetBool("private")
+	if err != nil {
+		panic(err)
+	}
+
+	return nil
+}
+
+func (rs *restorer) run(c *cmds.Command, args []string) error {
+	c.SilenceUsage = true
+	if len(gnet.Conf.Organizations) != 1 {
+		return gnet.Errorf(gnet.ConfigError, "Give a single organization to restore the repository to.")
+	}
+	org := gnet.Conf.Organization
+	if _, _, err := gnet.Credentials(); err != nil {
+		return err
+	}
+	// fail up front if the token lacks scopes of the command:
+	// creating the repository and pushing to it
+	permissions := gnet.Permissions{"administration": "write", "contents": "write"}
+	if err := checkScopes(nil, nil, permissions, "repo"); err != nil {
+		return err
+	}
+
+	// 1. extract the archive into a temporary folder
+	tmp, err := ioutil.TempDir("", "ghorgs-restore-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	fmt.Printf("Extracting '%s'...\n", rs.archive)
+	if err = utils.TarGzExtract(rs.archive, tmp); err != nil {
+		return err
+	}
+	dir, err := findRepository(tmp)
+	if err != nil {
+		return fmt.Errorf("%s in archive '%s'.\n", err.Error(), rs.archive)
+	}
+
+	// 2. find the branches and tags to push
+	head, refspecs, err := refspecsOf(dir)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Found %d branches in '%s' (default %s).\n",
+		len(refspecs)-1, rs.archive, strings.TrimPrefix(head, localBranches))
+
+	// 3. create the repository
+	//     POST /orgs/:org/repos
+	err = rs.request(http.MethodPost,
+		path.Join("orgs", org, "repos"),
+		map[string]interface{}{"name": rs.name, "private": rs.private},
+		http.StatusCreated)
+	if err != nil {
+		return err
+	}
+
+	// 4. push the branches and tags
+	rest, err := gnet.RestUrl()
+	if err != nil {
+		return err
+	}
+	host, err := gnet.WebHost()
+	if err != nil {
+		return err
+	}
+	rawurl := (&url.URL{Scheme: rest.Scheme, Host: host, Path: "/" + path.Join(org, rs.name) + ".git"}).String()
+	user, pass, err := gnet.Credentials()
+	if err != nil {
+		return err
+	}
+	pushUrl, err := utils.Url(rawurl, host, user, pass)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Pushing to `%s`...\n", rawurl)
+	if utils.Debug.DryRun {
+		fmt.Printf("Executing: git push %s %s\n", rawurl, strings.Join(refspecs, " "))
+	} else if err = utils.GitPush(dir, pushUrl, refspecs...); err != nil {
+		return err
+	}
+
+	// 5. keep the default branch of the archived repository
+	//     PATCH /repos/:org/:repo
+	err = rs.request(http.MethodPatch,
+		path.Join(repos.GetName(), org, rs.name),
+		map[string]interface{}{"default_branch": strings.TrimPrefix(head, localBranches)},
+		http.StatusOK)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Repository %s/%s restored.\n", org, rs.name)
+	return nil
+}
+
+// request executes GitHub v3 request with the json body, expecting
+// the given status.
+func (rs *restorer) request(method, query string, body map[string]interface{}, expected int) error {
+	request, err := gnet.MakeGitHubV3Request(method, query)
+	if err != nil {
+		return err
+	}
+	buff, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	request.Query = string(buff)
+
+	if utils.Debug.DryRun {
+		fmt.Printf("Executing: %s %s %s\n", request.Url, request.Method, request.Query)
+		return nil
+	}
+	resp, status, err := request.Execute()
+	if err != nil {
+		return err
+	}
+	if utils.Debug.Verbose {
+		log.Print(string(resp))
+	}
+	if status.Code == http.StatusUnprocessableEntity {
+		return fmt.Errorf("HttpResponse: %s. Repository %s may exist already.\n", status.Status, rs.name)
+	}
+	if status.Code != expected {
+		return gnet.StatusError(status)
+	}
+	return nil
+}
+
+// findRepository returns the git repository closest to the root
+// of folder: a clone (with .git folder) or a bare repository.
+func findRepository(folder string) (string, error) {
+	found := ""
+	err := filepath.Walk(folder, func(f string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.IsDir() {
+			return nil
+		}
+		if found != "" && strings.Count(f, string(filepath.Separator)) >=
+			strings.Count(found, string(filepath.Separator)) {
+			return filepath.SkipDir
+		}
+
+		if isGitDir(filepath.Join(f, ".git")) || isGitDir(f) {
+			found = f
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if found == "" {
+		return "", fmt.Errorf("No git repository found")
+	}
+	return found, nil
+}
+
+// isGitDir tells whether the folder is a git directory, i.e. .git
+// of a clone or a bare repository.
+func isGitDir(dir string) bool {
+	for _, name := range []string{"HEAD", "objects", "refs"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// refspecsOf returns the ref HEAD of the repository at dir points to and
+// refspecs pushing all its branches and tags: remote-tracking branches
+// of a clone (which has just the default one locally), or local branches
+// of a bare repository.
+func refspecsOf(dir string) (string, []string, error) {
+	head, err := utils.GitHead(dir)
+	if err != nil {
+		return "", nil, err
+	}
+
+	refspecs := make([]string, 0)
+	remotes, err := utils.GitRefs(dir, remoteBranches)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, ref := range remotes {
+		if ref == remoteBranches+"HEAD" {
+			continue
+		}
+		refspecs = append(refspecs, ref+":"+localBranches+strings.TrimPrefix(ref, remoteBranches))
+	}
+	if len(refspecs) == 0 {
+		locals, err := utils.GitRefs(dir, localBranches)
+		if err != nil {
+			return "", nil, err
+		}
+		for _, ref := range locals {
+			refspecs = append(refspecs, ref+":"+ref)
+		}
+	}
+	if len(refspecs) == 0 {
+		return "", nil, fmt.Errorf("No branches found in `%s`.\n", dir)
+	}
+
+	return head, append(refspecs, "refs/tags/*:refs/tags/*"), nil
+}
@@ -11,26 +11,85 @@ import (
 	"ghorgs/utils"
 	cmds "github.com/spf13/cobra"
 	flags "github.com/spf13/viper"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
 var rootCmd = &cmds.Command{
-	Use:              "ghorgs",
-	Short:            "ghorgs = GitHub ORGanizationS",
-	Long:             "ghorgs = GitHub ORGanizationS\nA simple cli tool to manage organizational accounts on GitHub.",
-	PersistentPreRun: initFlags,
+	Use:               "ghorgs",
+	Short:             "ghorgs = GitHub ORGanizationS",
+	Long:              "ghorgs = GitHub ORGanizationS\nA simple cli tool to manage organizational accounts on GitHub.",
+	PersistentPreRunE: initFlags,
+	SilenceErrors:     true,
 }
 
-func initFlags(c *cmds.Command, args []string) {
+func initFlags(c *cmds.Command, args []string) error {
+	if err := initConfig(); err != nil {
+		// not a usage error
+		c.SilenceUsage = true
+		return err
+	}
+
 	gnet.Conf.User = flags.GetString("user")
 	gnet.Conf.Token = flags.GetString("token")
+	// --token flag overrides GitHub App of configuration
+	if c.Flags().Changed("token") {
+		gnet.Conf.AppId = 0
+	}
 	gnet.Conf.Organization = flags.GetString("organization")
+	// --organization flag overrides `organizations` list
+	// of configuration, which overrides `organization`
+	if c.Flags().Changed("organization") || len(gnet.Conf.Organizations) == 0 {
+		gnet.Conf.Organizations = make([]string, 0)
+		for _, org := range strings.Split(gnet.Conf.Organization, ",") {
+			if org = strings.TrimSpace(org); org != "" {
+				gnet.Conf.Organizations = append(gnet.Conf.Organizations, org)
+			}
+		}
+	}
+	gnet.Conf.Organization = ""
+	if len(gnet.Conf.Organizations) > 0 {
+		gnet.Conf.Organization = gnet.Conf.Organizations[0]
+	}
 	utils.Debug.Verbose = flags.GetBool("verbose")
 	utils.Debug.DryRun = flags.GetBool("dry-run")
+	utils.Git.CaFile = gnet.Conf.CaFile
+
+	var err error
+	maxAge, err = parseAge(flags.GetString("max-age"))
+	return err
 }
 
-func init() {
-	cmds.OnInitialize(initConfig)
+// maxAge of stored snapshot reused by Cache instead of querying GitHub,
+// 0 means always query
+var maxAge time.Duration
+
+// parseAge parses a duration like time.ParseDuration, accepting also
+// days, e.g. 7d.
+func parseAge(age string) (time.Duration, error) {
+	if age == "" {
+		return 0, nil
+	}
 
+	var d time.Duration
+	var err error
+	if strings.HasSuffix(age, "d") {
+		var days int
+		days, err = strconv.Atoi(strings.TrimSuffix(age, "d"))
+		d = time.Duration(days) * 24 * time.Hour
+	} else {
+		d, err = time.ParseDuration(age)
+	}
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("Invalid age `%s`. Use e.g. 90m, 12h or 7d.\n", age)
+	}
+	return d, nil
+}
+
+func init() {
 	rootCmd.PersistentFlags().BoolP("dry-run",
 		"d",
 		false,
@@ -52,7 +111,7 @@ func init() {
 	rootCmd.PersistentFlags().StringP("token",
 		"t",
 		"",
-		"Security token used on Github. Overrides the token from configuration file.\n"+
+		"Security token used on Github. Overrides the token (or GitHub App) from configuration file.\n"+
 			"  Required GitHub scopes covered by a single token in the config file are:\n"+
 			"    - user,\n"+
 			"    - delete_repo,\n"+
@@ -68,32 +127,47 @@ func init() {
 			" used in the command line for different commands.")
 	flags.BindPFlag("token", rootCmd.PersistentFlags().Lookup("token")) // nolint
 
+	rootCmd.PersistentFlags().String("max-age",
+		"",
+		"Use the latest stored snapshot of an entity not older than this (e.g. 90m, 12h, 7d)\n"+
+			"instead of querying GitHub. If empty, GitHub is always queried.")
+	flags.BindPFlag("max-age", rootCmd.PersistentFlags().Lookup("max-age")) // nolint
+
 	rootCmd.PersistentFlags().StringP("organization",
 		"o",
 		"",
-		"Organizational account on GitHub analyzed.")
+		"Organizational account on GitHub analyzed, or a comma separated list of them.")
 
 	flags.BindPFlag("organization", rootCmd.PersistentFlags().Lookup("organization")) // nolint
 }
 
-func initConfig() {
+// initConfig reads the configuration file into gnet.Conf, returning
+// ConfigError if it's malformed.
+func initConfig() error {
 	flags.AddConfigPath(gnet.ConfigPath)
+	if dir, err := os.UserConfigDir(); err == nil {
+		flags.AddConfigPath(filepath.Join(dir, gnet.ConfigDir))
+	}
 	flags.SetConfigName(gnet.ConfigName)
 	flags.SetConfigType(gnet.ConfigType)
 
+	flags.SetDefault("url", gnet.DefaultUrl)
+	flags.SetDefault("per_page", gnet.DefaultPerPage)
+	flags.SetDefault("time_out", gnet.DefaultTimeOut)
+	flags.SetDefault("workers", gnet.DefaultWorkers)
+
 	if err := flags.ReadInConfig(); err != nil {
-		if _, ok := err.(flags.ConfigFileNotFoundError); ok {
-			// ignore, issue warning
-			fmt.Printf("Warning: config file not found.")
-			return
+		if _, ok := err.(flags.ConfigFileNotFoundError); !ok {
+			return gnet.Errorf(gnet.ConfigError, "Fatal config error: %s", err)
 		}
-
-		panic(fmt.Errorf("Fatal config error: %s", err))
+		// ignore, issue warning and continue with defaults
+		fmt.Printf("Warning: config file not found.")
 	}
 
 	if err := flags.Unmarshal(&gnet.Conf); err != nil {
-		panic(fmt.Errorf("Fatal config error: %s", err))
+		return gnet.Errorf(gnet.ConfigError, "Fatal config error: %s", err)
 	}
+	return nil
 }
 
 func Execute() error {
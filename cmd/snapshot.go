@@ -0,0 +1,107 @@
+//
+// Copyright (c) 2019 Sony Mobile Communications Inc.
+// SPDX-License-Identifier: MIT
+//
+
+package cmd
+
+import (
+	"fmt"
+	"ghorgs/model"
+	"ghorgs/view"
+	cmds "github.com/spf13/cobra"
+	"path/filepath"
+	"strings"
+)
+
+const snapshotUsage = `Comma separated list of files from previous dumps (csv, tsv, json or ndjson)
+to use instead of querying GitHub, e.g. repos.json.
+Files are matched to entities by their names without extension, e.g. repos.json to repos,
+unless the command works with a single entity.`
+
+func addSnapshotFlag(c *cmds.Command) {
+	c.Flags().String("from-snapshot",
+		"",
+		snapshotUsage)
+}
+
+// validateSnapshot reads --from-snapshot flag and matches the files to
+// entities. It returns the file names keyed by entity names.
+func validateSnapshot(c *cmds.Command, entities []model.Entity) (map[string]string, error) {
+	files, err := c.Flags().GetString("from-snapshot")
+	if err != nil {
+		panic(err)
+	}
+	if files == "" {
+		return nil, nil
+	}
+
+	snapshots := make(map[string]string)
+	for _, file := range strings.Split(files, ",") {
+		name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		if len(entities) == 1 {
+			name = entities[0].GetName()
+		}
+		if _, ok := model.EntityMap[name]; !ok {
+			return nil, fmt.Errorf("Cannot tell the entity of snapshot `%s`. "+
+				"Name the file after one of: %s.\n", file, sliceToStr(model.EntityNamesList()))
+		}
+		if _, ok := snapshots[name]; ok {
+			return nil, fmt.Errorf("More than one snapshot of %s given.\n", name)
+		}
+		snapshots[name] = file
+	}
+
+	return snapshots, nil
+}
+
+// Snapshot reads tables of the requested entities from the snapshot
+// files keyed by entity names. It is the offline counterpart of Cache.
+// If fields are given, the tables contain only those columns, in the
+// given order, otherwise the columns of the snapshots.
+func Snapshot(snapshots map[string]string,
+	request []model.Entity,
+	fields ...string) (map[string]*model.Table, error) {
+	result := make(map[string]*model.Table, len(request))
+	for _, entity := range request {
+		file, ok := snapshots[entity.GetName()]
+		if !ok {
+			return result, fmt.Errorf("No snapshot of %s given.\n", entity.GetName())
+		}
+		fmt.Printf("\nReading %s from `%s`...\n", entity.GetName(), file)
+
+		t, err := view.ReadTable(file, entity)
+		if err != nil {
+			return result, err
+		}
+
+		if len(fields) > 0 {
+			if t, err = t.Project(fields); err != nil {
+				return result, fmt.Errorf("Snapshot `%s` is missing a field. %s", file, err.Error())
+			}
+		}
+		result[entity.GetName()] = t
+	}
+
+	return result, nil
+}
+
+// load gets the tables of requested entities with all their fields
+// (like Cache without fields) from snapshots if any are given,
+// or from GitHub otherwise.
+func load(snapshots map[string]string, request []model.Entity) (map[string]*model.Table, error) {
+	if len(snapshots) == 0 {
+		return Cache(request)
+	}
+
+	result := make(map[string]*model.Table, len(request))
+	for _, entity := range request {
+		ca, err := Snapshot(snapshots, []model.Entity{entity}, entity.MakeTable().FieldNames()...)
+		if err != nil {
+			return result, err
+		}
+		result[entity.GetName()] = ca[entity.GetName()]
+	}
+
+	return result, nil
+}
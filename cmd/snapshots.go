@@ -0,0 +1,235 @@
+//
+// Copyright (c) 2019 Sony Mobile Communications Inc.
+// SPDX-License-Identifier: MIT
+//
+
+package cmd
+
+import (
+	"fmt"
+	"ghorgs/gnet"
+	"ghorgs/model"
+	"ghorgs/store"
+	"ghorgs/utils"
+	cmds "github.com/spf13/cobra"
+	"strings"
+	"time"
+)
+
+type snapshotter struct {
+	entity    string
+	allOrgs   bool
+	olderThan time.Duration
+	keep      int
+	id        store.Snapshot
+}
+
+var (
+	st           = &snapshotter{}
+	snapshotsCmd = &cmds.Command{
+		Use:   "snapshots",
+		Short: "List, show and prune snapshots stored by previous runs.",
+		Long: `Each query of GitHub is stored as a snapshot of the entity of the organization,
+which can be listed, shown and pruned with the subcommands, and reused with --max-age.`,
+	}
+	snapshotsListCmd = &cmds.Command{
+		Use:   "list",
+		Short: "List stored snapshots of the organization.",
+		Args:  st.validateFilter,
+		RunE:  st.list,
+	}
+	snapshotsShowCmd = &cmds.Command{
+		Use:   "show ID",
+		Short: "Show the records of a stored snapshot, e.g. myorg/repos/20210301T120000Z.",
+		Args:  st.validateShow,
+		RunE:  st.show,
+	}
+	snapshotsPruneCmd = &cmds.Command{
+		Use:   "prune",
+		Short: "Remove old stored snapshots of the organization.",
+		Args:  st.validatePrune,
+		RunE:  st.prune,
+	}
+)
+
+func init() {
+	for _, c := range []*cmds.Command{snapshotsListCmd, snapshotsPruneCmd} {
+		c.Flags().StringP("entity",
+			"e",
+			"",
+			"Entity of the snapshots, one of: "+sliceToStr(model.EntityNamesList())+". If empty, all entities.")
+
+		c.Flags().Bool("all-organizations",
+			false,
+			"Snapshots of all organizations instead of just the ones given by --organization.")
+	}
+
+	snapshotsPruneCmd.Flags().String("older-than",
+		"",
+		"Remove snapshots older than this, e.g. 12h or 30d.")
+
+	snapshotsPruneCmd.Flags().Int("keep",
+		0,
+		"Keep this number of the latest snapshots of each entity of an organization and remove the others.\n"+
+			"If used together with --older-than, only the snapshots matching both are removed.")
+
+	snapshotsCmd.AddCommand(snapshotsListCmd, snapshotsShowCmd, snapshotsPruneCmd)
+	rootCmd.AddCommand(snapshotsCmd)
+}
+
+func (st *snapshotter) validateFilter(c *cmds.Command, args []string) error {
+	var err error
+	st.entity, err = c.Flags().GetString("entity")
+	if err != nil {
+		panic(err)
+	}
+	if _, ok := model.EntityMap[st.entity]; st.entity != "" && !ok {
+		return fmt.Errorf("Unknown entity: %s\n", st.entity)
+	}
+
+	st.allOrgs, err = c.Flags().GetBool("all-organizations")
+	if err != nil {
+		panic(err)
+	}
+
+	return nil
+}
+
+func (st *snapshotter) validateShow(c *cmds.Command, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("Give the ID of a snapshot to show (see `ghorgs snapshots list`).\n")
+	}
+
+	var err error
+	st.id, err = store.ParseId(args[0])
+	return err
+}
+
+func (st *snapshotter) validatePrune(c *cmds.Command, args []string) error {
+	err := st.validateFilter(c, args)
+	if err != nil {
+		return err
+	}
+
+	age, err := c.Flags().GetString("older-than")
+	if err != nil {
+		panic(err)
+	}
+	st.olderThan, err = parseAge(age)
+	if err != nil {
+		return err
+	}
+
+	st.keep, err = c.Flags().GetInt("keep")
+	if err != nil {
+		panic(err)
+	}
+	if st.keep < 0 {
+		return fmt.Errorf("Insert --keep greater than 0.")
+	}
+
+	if st.olderThan == 0 && st.keep == 0 {
+		return fmt.Errorf("No criteria for pruning provided. Use --older-than and/or --keep.")
+	}
+
+	return nil
+}
+
+// snapshots returns the stored snapshots selected by the flags
+func (st *snapshotter) snapshots() ([]store.Snapshot, error) {
+	if st.allOrgs {
+		return store.List("", st.entity)
+	}
+	if len(gnet.Conf.Organizations) == 0 {
+		return nil, gnet.Errorf(gnet.ConfigError,
+			"No organization given. Use --organization or --all-organizations.")
+	}
+
+	result := make([]store.Snapshot, 0)
+	for _, org := range gnet.Conf.Organizations {
+		snapshots, err := store.List(org, st.entity)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, snapshots...)
+	}
+	return result, nil
+}
+
+func (st *snapshotter) list(c *cmds.Command, args []string) error {
+	c.SilenceUsage = true
+	snapshots, err := st.snapshots()
+	if err != nil {
+		return err
+	}
+	if len(snapshots) == 0 {
+		fmt.Printf("There are no snapshots in %s.\n", store.Dir())
+		return nil
+	}
+
+	fmt.Printf("ID\tTime\tRecords\tFields\n")
+	for _, s := range snapshots {
+		t, err := store.Load(s)
+		if err != nil {
+			fmt.Printf("%s\t%s\t-\t%s\n", s.Id(), s.Time.Local().Format(time.RFC3339), err.Error())
+			continue
+		}
+		fmt.Printf("%s\t%s\t%d\t%s\n", s.Id(), s.Time.Local().Format(time.RFC3339),
+			len(t.Keys), sliceToStr(t.FieldNames()))
+	}
+
+	return nil
+}
+
+func (st *snapshotter) show(c *cmds.Command, args []string) error {
+	c.SilenceUsage = true
+	t, err := store.Load(st.id)
+	if err != nil {
+		return &gnet.Error{Kind: gnet.NotFoundError, Err: err}
+	}
+
+	fmt.Printf("%s\n", strings.Join(t.FieldNames(), "\t"))
+	if len(t.Keys) > 0 {
+		fmt.Printf("%s\n", t)
+	}
+	return nil
+}
+
+func (st *snapshotter) prune(c *cmds.Command, args []string) error {
+	c.SilenceUsage = true
+	snapshots, err := st.snapshots()
+	if err != nil {
+		return err
+	}
+
+	// snapshots are ordered by organization, entity and time,
+	// so count the snapshots newer than each one from the end
+	newer := make(map[string]int)
+	removed := 0
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		s := snapshots[i]
+		group := s.Organization + "/" + s.Entity
+		n := newer[group]
+		newer[group]++
+
+		if st.keep > 0 && n < st.keep {
+			continue
+		}
+		if st.olderThan > 0 && time.Since(s.Time) <= st.olderThan {
+			continue
+		}
+
+		fmt.Printf("Removing %s...\n", s.Id())
+		if utils.Debug.DryRun {
+			continue
+		}
+		if err = store.Remove(s); err != nil {
+			fmt.Println("Error!", err.Error())
+			continue
+		}
+		removed++
+	}
+	fmt.Printf("Removed %d of %d snapshots.\n", removed, len(snapshots))
+
+	return nil
+}
@@ -0,0 +1,173 @@
+//
+// Copyright (c) 2019 Sony Mobile Communications Inc.
+// SPDX-License-Identifier: MIT
+//
+
+package cmd
+
+import (
+	"fmt"
+	"ghorgs/gnet"
+	"ghorgs/model"
+	"ghorgs/utils"
+	cmds "github.com/spf13/cobra"
+	"regexp"
+	"strings"
+)
+
+type unarchiver struct {
+	quiet     bool
+	names     []string
+	where     string
+	snapshots map[string]string
+	data      map[string]*model.Table
+}
+
+var (
+	ua           = &unarchiver{}
+	unarchiveCmd = &cmds.Command{
+		Use:   "unarchive",
+		Short: "Unarchive GitHub repositories archived on GitHub according to given criteria.",
+		Long: `Unarchive GitHub repositories archived on GitHub (e.g. by archive) according
+to given criteria, i.e. make them writable again.`,
+		Args: ua.validateArgs,
+		RunE: ua.run,
+	}
+)
+
+func init() {
+	unarchiveCmd.Flags().BoolP("quiet",
+		"q",
+		false,
+		"DO NOT ask user for confirmation."+
+			"(Use with care, e.g. in scripts where interaction is minimal or impossible.)")
+
+	unarchiveCmd.Flags().StringP("repos",
+		"r",
+		"",
+		`Comma separated list of repositories to unarchive.
+
+* Name can contain alphanumeric and special characters '_', '.' and '-'.
+`)
+
+	addWhereFlag(unarchiveCmd)
+	addSnapshotFlag(unarchiveCmd)
+
+	rootCmd.AddCommand(unarchiveCmd)
+}
+
+func (ua *unarchiver) addCache(c map[string]*model.Table) {
+	ua.data = c
+}
+
+func (ua *unarchiver) validateArgs(c *cmds.Command, args []string) error {
+	var err error
+	ua.quiet, err = c.Flags().GetBool("quiet")
+	if err != nil {
+		panic(err)
+	}
+
+	repos, err := c.Flags().GetString("repos")
+	if err != nil {
+		panic(err)
+	}
+	if repos != "" {
+		matched, err := regexp.MatchString(`^[\.|\-|\_|[:alnum:]]+(\,[\.|\-|\_|[:alnum:]]+)*$`, repos)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			return fmt.Errorf("--repos can only contain a comma separated list of repository names " +
+				"written in ascii alpha-numeric characters ([._-] are allowed.).")
+		}
+
+		ua.names = strings.Split(repos, ",")
+	}
+
+	ua.where, _, err = validateWhere(c, []model.Entity{model.Repos})
+	if err != nil {
+		return err
+	}
+
+	ua.snapshots, err = validateSnapshot(c, []model.Entity{model.Repos})
+	if err != nil {
+		return err
+	}
+
+	if len(ua.names) == 0 && ua.where == "" {
+		return fmt.Errorf("No criteria for unarchiving provided. Use --repos and/or --where.")
+	}
+
+	return nil
+}
+
+func (ua *unarchiver) run(c *cmds.Command, args []string) error {
+	c.SilenceUsage = true
+	// fail up front if the token lacks scopes of the command
+	if err := checkScopes(ua.snapshots, []model.Entity{repos},
+		gnet.Permissions{"administration": "write"}, "repo"); err != nil {
+		return err
+	}
+
+	// 0. get cache for repos
+	ca, err := load(ua.snapshots, []model.Entity{repos})
+	if err != nil {
+		return err
+	}
+
+	ua.addCache(ca)
+
+	// 1. select the repositories by --repos and --where
+	projection := ua.data[repos.GetName()]
+	if ua.names != nil {
+		projection, err = projection.FindAllByFieldValues(reposFields.Name.Name, ua.names)
+		if err != nil {
+			if projection == nil {
+				// nothing to work with so just return
+				return &gnet.Error{Kind: gnet.NotFoundError, Err: err}
+			}
+			fmt.Println(err.Error())
+		}
+	}
+
+	if ua.where != "" {
+		projection, err = projection.Where(ua.where)
+		if err != nil {
+			return err
+		}
+	}
+
+	// 2. only archived repositories can be unarchived
+	projection, err = projection.Where(reposFields.Archived.Name + " == true")
+	if err != nil {
+		return err
+	}
+
+	if len(projection.Keys) == 0 {
+		fmt.Println("There are no archived repositories with requested criteria. Exiting.")
+		return nil
+	}
+
+	// 3. display the result to the user and request confirmation
+	msg := "\nThe following repositories will be unarchived on GitHub "
+	fmt.Printf(msg+"(%d):\n", len(projection.Keys))
+	fmt.Printf("%s\n", projection)
+
+	if !ua.quiet && !utils.GetUserConfirmation() {
+		return nil
+	}
+
+	// 4. unarchive them
+	for _, key := range projection.Keys {
+		record := projection.Records[key]
+		err = setArchived(record[reposFields.Organization.Index], record[reposFields.Name.Index], false, nil)
+		if gerr, ok := err.(*gnet.Error); ok && gerr.Kind == gnet.AuthError {
+			return err
+		}
+		if err != nil {
+			fmt.Println("Error!", err.Error())
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+//
+// Copyright (c) 2019 Sony Mobile Communications Inc.
+// SPDX-License-Identifier: MIT
+//
+
+package cmd
+
+import (
+	"ghorgs/model"
+	"ghorgs/utils"
+	cmds "github.com/spf13/cobra"
+)
+
+const whereUsage = `Filter expression selecting the records, e.g.
+    'DiskUsage > 100000 && Type == "PRIVATE" && Name =~ "^legacy-"'
+
+* Comparisons of fields with literals: ==, !=, <, <=, >, >=,
+  =~ (matches regular expression), !~ (doesn't match regular expression).
+* Combined with &&, ||, ! and parentheses.
+* Literals are strings in double quotes, numbers, true and false.
+* Fields are referred by name without spaces and units (e.g. DiskUsage, LastPush),
+  or by full name in backticks (e.g. ` + "`Last Push`" + `).
+`
+
+func addWhereFlag(c *cmds.Command) {
+	c.Flags().StringP("where",
+		"w",
+		"",
+		whereUsage)
+}
+
+// validateWhere reads --where flag and checks that the expression is
+// valid for all the entities. It returns the expression and the names
+// of the fields it uses.
+func validateWhere(c *cmds.Command, entities []model.Entity) (string, []string, error) {
+	where, err := c.Flags().GetString("where")
+	if err != nil {
+		panic(err)
+	}
+	if where == "" {
+		return "", nil, nil
+	}
+
+	fields := make([]string, 0)
+	for _, entity := range entities {
+		f, err := model.ParseFilter(where, entity.MakeTable().Fields)
+		if err != nil {
+			return "", nil, err
+		}
+		for _, name := range f.FieldNames() {
+			if !utils.StringInSlice(name, fields) {
+				fields = append(fields, name)
+			}
+		}
+	}
+
+	return where, fields, nil
+}
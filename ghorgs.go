@@ -18,6 +18,7 @@ func init() {
 
 func main() {
 	if err := cmd.Execute(); err != nil {
-		fmt.Println(err)
+		fmt.Println("Error!", err)
+		os.Exit(cmd.ExitCode(err))
 	}
 }
@@ -0,0 +1,196 @@
+//
+// Copyright (c) 2019 Sony Mobile Communications Inc.
+// SPDX-License-Identifier: MIT
+//
+
+package gnet
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+)
+
+const (
+	// appTokenUser is the user name of git credentials
+	// with an installation access token
+	appTokenUser = "x-access-token"
+	// refresh installation token this long before it expires,
+	// so that requests (and clones) started with it don't fail
+	appTokenMargin = 5 * time.Minute
+	// lifetime of JWT of the app, at most 10 minutes on GitHub
+	appJwtLifetime = 9 * time.Minute
+)
+
+// installationToken is the access token of the installation of GitHub App
+// shared by all requests.
+type installationToken struct {
+	sync.Mutex
+	token   string
+	expires time.Time
+	// permissions granted to the installation, e.g. contents: write
+	permissions map[string]string
+}
+
+var appToken = &installationToken{}
+
+// IsApp tells whether ghorgs authenticates as an installation
+// of GitHub App (`app_id` in configuration) instead of by token.
+func IsApp() bool {
+	return Conf.AppId != 0
+}
+
+// Token returns the token authorizing requests to GitHub: the access token
+// of the installation of GitHub App, refreshed before it expires, or the
+// token of configuration.
+func Token() (string, error) {
+	if !IsApp() {
+		if Conf.Token == "" {
+			return "", Errorf(AuthError, "Missing GitHub token.")
+		}
+		return Conf.Token, nil
+	}
+
+	return appToken.get()
+}
+
+// Credentials returns the user and the password of `git clone`: the user
+// and the token of configuration, or x-access-token and the access token
+// of the installation of GitHub App.
+func Credentials() (string, string, error) {
+	token, err := Token()
+	if err != nil {
+		return "", "", err
+	}
+	if IsApp() {
+		return appTokenUser, token, nil
+	}
+
+	if Conf.User == "" {
+		return "", "", Errorf(AuthError, "Invalid credentials.")
+	}
+	return Conf.User, token, nil
+}
+
+// get returns the access token, requesting a new one if it's missing
+// or about to expire.
+func (t *installationToken) get() (string, error) {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.token != "" && time.Until(t.expires) > appTokenMargin {
+		return t.token, nil
+	}
+
+	jwt, err := appJwt(time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	// POST /app/installations/:installation_id/access_tokens
+	u, err := RestUrl()
+	if err != nil {
+		return "", err
+	}
+	u.Path = path.Join(u.Path, "app", "installations",
+		fmt.Sprintf("%d", Conf.InstallationId), "access_tokens")
+	request := &Request{u.String(),
+		postMethod,
+		map[string]string{
+			"Authorization": "Bearer " + jwt,
+			"Accept":        "application/vnd.github+json",
+		},
+		"",
+		time.Duration(Conf.TimeOut) * time.Second,
+		nil}
+
+	resp, status, err := request.Execute()
+	if err != nil {
+		return "", err
+	}
+	if status.Code != http.StatusCreated {
+		return "", Errorf(AuthError, "Cannot get access token of installation %d of GitHub App %d: %s",
+			Conf.InstallationId, Conf.AppId, status.Status)
+	}
+
+	var token struct {
+		Token       string            `json:"token"`
+		ExpiresAt   time.Time         `json:"expires_at"`
+		Permissions map[string]string `json:"permissions"`
+	}
+	if err = json.Unmarshal(resp, &token); err != nil || token.Token == "" {
+		return "", Errorf(DecodeError, "Invalid access token of installation %d of GitHub App %d.",
+			Conf.InstallationId, Conf.AppId)
+	}
+
+	t.token = token.Token
+	t.expires = token.ExpiresAt
+	t.permissions = token.Permissions
+	return t.token, nil
+}
+
+// appJwt returns JSON Web Token of GitHub App signed by its private key
+// (RS256), see https://docs.github.com/en/apps/creating-github-apps/authenticating-with-a-github-app
+func appJwt(now time.Time) (string, error) {
+	if Conf.InstallationId == 0 || Conf.PrivateKeyFile == "" {
+		return "", Errorf(ConfigError, "GitHub App needs `installation_id` and `private_key_file`.")
+	}
+	key, err := readPrivateKey(Conf.PrivateKeyFile)
+	if err != nil {
+		return "", err
+	}
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		// backdated against clock drift
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(appJwtLifetime).Unix(),
+		"iss": fmt.Sprintf("%d", Conf.AppId),
+	})
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", &Error{ConfigError, err}
+	}
+	return unsigned + "." + enc.EncodeToString(signature), nil
+}
+
+// readPrivateKey reads RSA private key from PEM file in PKCS #1
+// (as generated by GitHub) or PKCS #8 format.
+func readPrivateKey(file string) (*rsa.PrivateKey, error) {
+	buff, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, &Error{ConfigError, err}
+	}
+	block, _ := pem.Decode(buff)
+	if block == nil {
+		return nil, Errorf(ConfigError, "No private key found in `%s`.", file)
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, Errorf(ConfigError, "Invalid private key in `%s`: %s", file, err.Error())
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, Errorf(ConfigError, "Private key in `%s` is not an RSA key.", file)
+	}
+	return rsaKey, nil
+}
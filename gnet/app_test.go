@@ -0,0 +1,219 @@
+//
+// Copyright (c) 2019 Sony Mobile Communications Inc.
+// SPDX-License-Identifier: MIT
+//
+
+package gnet
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeKeys writes the key in PEM files of all formats supported or not,
+// and returns their paths by format.
+func writeKeys(t *testing.T, dir string, key *rsa.PrivateKey) map[string]string {
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ec, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecPkcs8, err := x509.MarshalPKCS8PrivateKey(ec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blocks := map[string]*pem.Block{
+		"pkcs1":   {Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)},
+		"pkcs8":   {Type: "PRIVATE KEY", Bytes: pkcs8},
+		"ec":      {Type: "PRIVATE KEY", Bytes: ecPkcs8},
+		"invalid": {Type: "PRIVATE KEY", Bytes: []byte("not a key")},
+	}
+	files := make(map[string]string)
+	for name, block := range blocks {
+		files[name] = filepath.Join(dir, name+".pem")
+		if err = ioutil.WriteFile(files[name], pem.EncodeToMemory(block), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files["empty"] = filepath.Join(dir, "empty.pem")
+	if err = ioutil.WriteFile(files["empty"], []byte("no pem here\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	files["missing"] = filepath.Join(dir, "missing.pem")
+	return files
+}
+
+func TestReadPrivateKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ghorgs-app-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := writeKeys(t, dir, key)
+
+	for _, name := range []string{"pkcs1", "pkcs8"} {
+		read, err := readPrivateKey(files[name])
+		if err != nil {
+			t.Errorf("%s: readPrivateKey failed: %s", name, err.Error())
+			continue
+		}
+		if !read.Equal(key) {
+			t.Errorf("%s: readPrivateKey read another key", name)
+		}
+	}
+	for _, name := range []string{"ec", "invalid", "empty", "missing"} {
+		_, err := readPrivateKey(files[name])
+		if gerr, ok := err.(*Error); !ok || gerr.Kind != ConfigError {
+			t.Errorf("%s: readPrivateKey failed with %v, want ConfigError", name, err)
+		}
+	}
+}
+
+func TestAppJwt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ghorgs-app-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := writeKeys(t, dir, key)
+	conf := Conf
+	defer func() { Conf = conf }()
+
+	// installation and key are needed
+	Conf = gitHubConfiguration{AppId: 42, PrivateKeyFile: files["pkcs1"]}
+	if _, err = appJwt(time.Now()); err == nil {
+		t.Errorf("appJwt without installation_id didn't fail")
+	}
+	Conf = gitHubConfiguration{AppId: 42, InstallationId: 7}
+	if _, err = appJwt(time.Now()); err == nil {
+		t.Errorf("appJwt without private_key_file didn't fail")
+	}
+
+	Conf = gitHubConfiguration{AppId: 42, InstallationId: 7, PrivateKeyFile: files["pkcs1"]}
+	now := time.Unix(1600000000, 0)
+	jwt, err := appJwt(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		t.Fatalf("JWT %s has %d parts, want 3", jwt, len(parts))
+	}
+	enc := base64.RawURLEncoding
+	var header map[string]string
+	var claims map[string]interface{}
+	for i, v := range []interface{}{&header, &claims} {
+		buff, err := enc.DecodeString(parts[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = json.Unmarshal(buff, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if header["alg"] != "RS256" || header["typ"] != "JWT" {
+		t.Errorf("header = %v, want RS256 JWT", header)
+	}
+	// backdated iat, exp within 10 minutes
+	if claims["iss"] != "42" || claims["iat"] != float64(now.Unix()-60) ||
+		claims["exp"] != float64(now.Add(appJwtLifetime).Unix()) {
+		t.Errorf("claims = %v", claims)
+	}
+
+	signature, err := enc.DecodeString(parts[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err = rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+		t.Errorf("signature of JWT is invalid: %s", err.Error())
+	}
+}
+
+func TestInstallationToken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ghorgs-app-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := writeKeys(t, dir, key)
+
+	expires := time.Now().Add(time.Hour)
+	requests := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Method != http.MethodPost || r.URL.Path != "/app/installations/7/access_tokens" ||
+			!strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"token": "ghs_%d", "expires_at": "%s", "permissions": {"contents": "write"}}`,
+			requests, expires.UTC().Format(time.RFC3339))
+	}))
+	defer s.Close()
+	conf, token := Conf, appToken
+	defer func() { Conf, appToken = conf, token }()
+	Conf = gitHubConfiguration{Url: s.URL, TimeOut: 5, Token: "ignored",
+		AppId: 42, InstallationId: 7, PrivateKeyFile: files["pkcs8"]}
+	appToken = &installationToken{}
+
+	for i := 0; i < 2; i++ {
+		user, password, err := Credentials()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the token is reused until it's about to expire
+		if user != appTokenUser || password != "ghs_1" {
+			t.Errorf("Credentials() = %s, %s, want %s, ghs_1", user, password, appTokenUser)
+		}
+	}
+	if appToken.permissions["contents"] != "write" {
+		t.Errorf("permissions = %v, want contents: write", appToken.permissions)
+	}
+
+	expires = time.Now().Add(appTokenMargin / 2)
+	appToken.expires = expires
+	if token, err := Token(); err != nil || token != "ghs_2" {
+		t.Errorf("Token() = %s, %v, want refreshed ghs_2", token, err)
+	}
+
+	Conf.InstallationId = 8
+	appToken = &installationToken{}
+	if _, err := Token(); err == nil {
+		t.Errorf("Token() of unknown installation didn't fail")
+	}
+}
@@ -0,0 +1,80 @@
+//
+// Copyright (c) 2019 Sony Mobile Communications Inc.
+// SPDX-License-Identifier: MIT
+//
+
+package gnet
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// ErrorKind classifies errors which occur while querying GitHub,
+// so that callers can react to (and report) them distinctly.
+type ErrorKind int
+
+const (
+	// ConfigError denotes invalid or missing configuration
+	// (e.g. API url, organization or query templates).
+	ConfigError ErrorKind = iota + 1
+	// NetworkError denotes failure to reach GitHub or an unexpected
+	// HTTP response status.
+	NetworkError
+	// AuthError denotes missing or insufficient credentials.
+	AuthError
+	// NotFoundError denotes a resource that doesn't exist on GitHub
+	// (or isn't visible with the given credentials).
+	NotFoundError
+	// DecodeError denotes a response which could not be decoded.
+	DecodeError
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case ConfigError:
+		return "configuration error"
+	case NetworkError:
+		return "network error"
+	case AuthError:
+		return "authorization error"
+	case NotFoundError:
+		return "not found"
+	case DecodeError:
+		return "decode error"
+	}
+	return "unknown error"
+}
+
+// Error is the error type returned by gnet (and model) functions.
+type Error struct {
+	Kind ErrorKind
+	Err  error
+}
+
+// Errorf creates an Error of a given kind with a formatted message.
+func Errorf(kind ErrorKind, format string, a ...interface{}) *Error {
+	return &Error{kind, fmt.Errorf(format, a...)}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Kind, e.Err.Error())
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// StatusError converts an unsuccessful HTTP response status to an Error
+// of the matching kind.
+func StatusError(status *ResponseStatus) *Error {
+	kind := NetworkError
+	switch status.Code {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		kind = AuthError
+	case http.StatusNotFound:
+		kind = NotFoundError
+	}
+
+	return Errorf(kind, "HttpResponse: %s", status.Status)
+}
@@ -8,55 +8,154 @@ package gnet
 import (
 	"net/url"
 	"path"
+	"strings"
 	"time"
 )
 
 const (
 	ConfigPath = "./config"
+	ConfigDir  = "ghorgs" // in user's configuration directory
 	ConfigName = "config"
 	ConfigType = "yaml"
+
+	DefaultUrl     = "https://api.github.com"
+	DefaultPerPage = 50
+	DefaultTimeOut = 10
+	DefaultWorkers = 3
 )
 
 type gitHubConfiguration struct {
-	Url          string `mapstructure:"url"`
-	User         string `mapstructure:"user"`
-	Token        string `mapstructure:"token"`
-	Organization string `mapstructure:"organization"`
-	PerPage      int    `mapstructure:"per_page"`
-	TimeOut      int    `mapstructure:"time_out"`
+	Url string `mapstructure:"url"`
+	// Enterprise denotes GitHub Enterprise Server at Url,
+	// serving API v3 at /api/v3 and API v4 at /api/graphql.
+	Enterprise bool `mapstructure:"enterprise"`
+	// RestUrl and GraphQlUrl override the urls of API v3 and v4
+	// derived from Url.
+	RestUrl    string `mapstructure:"rest_url"`
+	GraphQlUrl string `mapstructure:"graphql_url"`
+	// CaFile is a PEM bundle of certificates trusted in addition
+	// to the system ones, e.g. of an enterprise CA.
+	CaFile string `mapstructure:"ca_file"`
+	User   string `mapstructure:"user"`
+	Token  string `mapstructure:"token"`
+	// AppId, InstallationId and PrivateKeyFile of GitHub App
+	// authenticate as its installation instead of by Token.
+	AppId          int64  `mapstructure:"app_id"`
+	InstallationId int64  `mapstructure:"installation_id"`
+	PrivateKeyFile string `mapstructure:"private_key_file"`
+	Organization   string `mapstructure:"organization"`
+	// Organizations analyzed in one run, given by `organizations` list
+	// in configuration or comma separated --organization flag.
+	// Organization is the first of them.
+	Organizations []string `mapstructure:"organizations"`
+	PerPage       int      `mapstructure:"per_page"`
+	TimeOut       int      `mapstructure:"time_out"`
+	QueriesDir    string   `mapstructure:"queries_dir"`
+	StateDir      string   `mapstructure:"state_dir"`
+	Workers       int      `mapstructure:"workers"`
 }
 
 var (
-	v4Path     = "/graphql"
-	postMethod = "POST"
-	Conf       gitHubConfiguration
+	v4Path           = "/graphql"
+	enterpriseV3Path = "/api/v3"
+	enterpriseV4Path = "/api/graphql"
+	postMethod       = "POST"
+	Conf             gitHubConfiguration
 )
 
+// RestUrl returns the url of GitHub REST API v3, i.e. `rest_url`
+// of configuration, or `url` (followed by /api/v3 in enterprise mode).
+func RestUrl() (*url.URL, error) {
+	if Conf.RestUrl != "" {
+		return parseUrl(Conf.RestUrl)
+	}
+
+	u, err := parseUrl(Conf.Url)
+	if err != nil {
+		return nil, err
+	}
+	if Conf.Enterprise {
+		u.Path = path.Join(u.Path, enterpriseV3Path)
+	}
+	return u, nil
+}
+
+// GraphQlUrl returns the url of GitHub GraphQL API v4, i.e. `graphql_url`
+// of configuration, or `url` followed by /graphql (/api/graphql in
+// enterprise mode).
+func GraphQlUrl() (*url.URL, error) {
+	if Conf.GraphQlUrl != "" {
+		return parseUrl(Conf.GraphQlUrl)
+	}
+
+	u, err := parseUrl(Conf.Url)
+	if err != nil {
+		return nil, err
+	}
+	if Conf.Enterprise {
+		u.Path = path.Join(u.Path, enterpriseV4Path)
+	} else {
+		u.Path = path.Join(u.Path, v4Path)
+	}
+	return u, nil
+}
+
+// WebHost returns the host serving the repositories, whose urls
+// are cloned with credentials of the configuration: github.com for
+// api.github.com, otherwise the host of the API (e.g. of GitHub
+// Enterprise Server).
+func WebHost() (string, error) {
+	u, err := RestUrl()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(u.Host, "api."), nil
+}
+
+func parseUrl(rawurl string) (*url.URL, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, &Error{ConfigError, err}
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, Errorf(ConfigError, "Invalid GitHub API url `%s`.", rawurl)
+	}
+	return u, nil
+}
+
 // MakeGitHubV3Request creates a Request object to access and
 // execute GitHub REST like API v3. See https://docs.github.com/en/rest
 // for reference (and in particular for the meaning of method and
 // query parameters).
 //
-//    method = HTTP verb [HEAD, GET, POST, PATCH, PUT, DELETE]
-//    query = specific resource path on GitHub API endpoint, e.g.
-//            /user/repos and similar.
-//    token = API authorization token
-func MakeGitHubV3Request(method, query, token string) *Request {
-	if Conf.Token == "" {
-		panic("Missing GitHub token.")
+//	method = HTTP verb [HEAD, GET, POST, PATCH, PUT, DELETE]
+//	query = specific resource path on GitHub API endpoint, e.g.
+//	        /user/repos and similar, optionally with parameters
+//	        like /user/repos?per_page=100.
+//
+// The request is authorized by the token of Token().
+func MakeGitHubV3Request(method, query string) (*Request, error) {
+	auth, err := Token()
+	if err != nil {
+		return nil, err
 	}
 
-	u, err := url.Parse(Conf.Url)
+	u, err := RestUrl()
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+	if i := strings.Index(query, "?"); i >= 0 {
+		u.RawQuery = query[i+1:]
+		query = query[:i]
 	}
 	u.Path = path.Join(u.Path, query)
 
 	return &Request{u.String(),
 		method,
-		map[string]string{"Authorization": "bearer " + Conf.Token},
+		map[string]string{"Authorization": "bearer " + auth},
 		query,
-		time.Duration(Conf.TimeOut) * time.Second}
+		time.Duration(Conf.TimeOut) * time.Second,
+		nil}, nil
 }
 
 // MakeGitHubV4Request creates a Request object to access and
@@ -64,23 +163,24 @@ func MakeGitHubV3Request(method, query, token string) *Request {
 // for reference (and in particular about the meaning of the query
 // parameter).
 //
-//     query = json representation of the graphql query
-//     token = API authorization token
-func MakeGitHubV4Request(query, token string) *Request {
-	if Conf.Token == "" {
-		panic("Missing GitHub token.")
+//	query = json representation of the graphql query
+//
+// The request is authorized by the token of Token().
+func MakeGitHubV4Request(query string) (*Request, error) {
+	auth, err := Token()
+	if err != nil {
+		return nil, err
 	}
 
-	u, err := url.Parse(Conf.Url)
+	u, err := GraphQlUrl()
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	u.Path = path.Join(u.Path, v4Path)
-
 	return &Request{u.String(),
 		postMethod,
-		map[string]string{"Authorization": "bearer " + Conf.Token},
+		map[string]string{"Authorization": "bearer " + auth},
 		query,
-		time.Duration(Conf.TimeOut) * time.Second}
+		time.Duration(Conf.TimeOut) * time.Second,
+		nil}, nil
 }
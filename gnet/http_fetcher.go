@@ -6,11 +6,16 @@
 package gnet
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
 	"ghorgs/utils"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -18,54 +23,184 @@ type Request struct {
 	Url     string
 	Method  string
 	Headers map[string]string
+	// Query is json of the graphql query of API v4 or the resource path
+	// of API v3. It is sent as body of POST, PATCH and PUT requests.
 	Query   string
 	Timeout time.Duration // in sec
+	// Progress reports waiting for rate limit reset or retry backoff
+	// of this request, the package's Progress if nil.
+	Progress func(msg string)
 }
 
-// ResponseStatus holds the HTTP code and status resulting from an HTTP request.
+// ResponseStatus holds the HTTP code, status and headers resulting from an HTTP request.
 type ResponseStatus struct {
 	Code   int
 	Status string
+	Header http.Header
 }
 
-// Execute runs a given http request and returns resulting response body in bytes
-// and  ResponseStatus (HTTP code and status).
-func (r *Request) Execute() ([]byte, *ResponseStatus) {
+// Execute runs a given http request, waiting for the rate limit reset when
+// the rate limit of the API is exhausted and retrying with exponential backoff
+// on transient failures, and returns resulting response body in bytes and
+// ResponseStatus (HTTP code and status). Unsuccessful HTTP status is not
+// an error by itself, it is up to the caller to interpret the ResponseStatus,
+// but failure to execute the request is returned as NetworkError.
+func (r *Request) Execute() ([]byte, *ResponseStatus, error) {
+	return r.run(nil)
+}
+
+// Download runs a given http request like Execute, but writes the body of
+// successful response to w while it's read instead of returning it, e.g.
+// of large files. The body of unsuccessful response is discarded.
+func (r *Request) Download(w io.Writer) (*ResponseStatus, error) {
+	_, responseStatus, err := r.run(w)
+	return responseStatus, err
+}
+
+// run executes the request with retries, writing the body of successful
+// response to w if it's not nil.
+func (r *Request) run(w io.Writer) ([]byte, *ResponseStatus, error) {
+	resource := coreResource
+	if isGraphQl(r.Url) {
+		resource = graphQlResource
+	}
+
+	for attempt := 0; ; attempt++ {
+		limits.wait(resource, r.progress)
+
+		bbody, responseStatus, err := r.execute(w)
+		if err != nil {
+			return nil, nil, err
+		}
+		limits.updateFromHeaders(resource, responseStatus.Header)
+		// successful responses, e.g. 201 Created, return their body,
+		// unless GraphQL API tells its rate limit is exhausted
+		success := responseStatus.Code >= http.StatusOK && responseStatus.Code < http.StatusMultipleChoices
+		if success && resource == graphQlResource {
+			limits.updateFromGraphQl(bbody)
+		}
+		if success && !graphQlRateLimited(bbody) {
+			return bbody, responseStatus, nil
+		}
+
+		wait, retry := retryAfter(responseStatus.Code, responseStatus.Header, bbody, attempt)
+		if !retry || attempt >= MaxRetries {
+			if success {
+				// errors of the body are up to the caller
+				return bbody, responseStatus, nil
+			}
+			if utils.Debug.Verbose {
+				log.Print(r.Query)
+			}
+			return nil, responseStatus, nil
+		}
+
+		status := responseStatus.Status
+		if success {
+			status = "GraphQL rate limit exceeded"
+		}
+		r.progress(fmt.Sprintf("%s, retrying in %s (%d/%d)...",
+			status, wait.Round(time.Second), attempt+1, MaxRetries))
+		time.Sleep(wait)
+	}
+}
+
+func (r *Request) progress(msg string) {
+	if r.Progress != nil {
+		r.Progress(msg)
+	} else {
+		Progress(msg)
+	}
+}
+
+// execute runs a single attempt of the request and returns the response
+// body (or writes the successful one to w) and status.
+func (r *Request) execute(w io.Writer) ([]byte, *ResponseStatus, error) {
 	requestQuery := ""
-	if r.Method == postMethod {
+	switch r.Method {
+	case postMethod, http.MethodPatch, http.MethodPut:
 		requestQuery = r.Query
 	}
 	requestBody := strings.NewReader(requestQuery)
 	req, err := http.NewRequest(r.Method, r.Url, requestBody)
 	if err != nil {
-		panic(err)
+		return nil, nil, &Error{ConfigError, err}
 	}
 
 	for key, header := range r.Headers {
 		req.Header.Set(key, header)
 	}
 
+	t, err := httpTransport()
+	if err != nil {
+		return nil, nil, err
+	}
 	var netClient = &http.Client{
-		Timeout: time.Second * r.Timeout,
+		Transport: t,
+		Timeout:   time.Second * r.Timeout,
 	}
 
 	response, err := netClient.Do(req)
 	if err != nil {
-		panic(err)
+		return nil, nil, &Error{NetworkError, err}
 	}
 	defer response.Body.Close()
 
-	responseStatus := &ResponseStatus{response.StatusCode, response.Status}
-	if responseStatus.Code != http.StatusOK {
-		if utils.Debug.Verbose {
-			log.Print(r.Query)
+	responseStatus := &ResponseStatus{response.StatusCode, response.Status, response.Header}
+	if w != nil && response.StatusCode >= http.StatusOK && response.StatusCode < http.StatusMultipleChoices {
+		if _, err = io.Copy(w, response.Body); err != nil {
+			return nil, nil, &Error{NetworkError, err}
 		}
-		return nil, responseStatus
+		return nil, responseStatus, nil
 	}
-
 	bbody, err := ioutil.ReadAll(response.Body)
 	if err != nil {
-		panic(err)
+		return nil, nil, &Error{NetworkError, err}
 	}
-	return bbody, responseStatus
+	return bbody, responseStatus, nil
+}
+
+// isGraphQl tells whether the url is of GitHub GraphQL API v4.
+func isGraphQl(rawurl string) bool {
+	if u, err := GraphQlUrl(); err == nil && u.String() == rawurl {
+		return true
+	}
+	return strings.HasSuffix(rawurl, v4Path)
+}
+
+var (
+	transportOnce sync.Once
+	transport     http.RoundTripper
+	transportErr  error
+)
+
+// httpTransport returns the transport shared by all requests, which
+// trusts certificates of `ca_file` of configuration in addition to
+// the system ones.
+func httpTransport() (http.RoundTripper, error) {
+	transportOnce.Do(func() {
+		if Conf.CaFile == "" {
+			transport = http.DefaultTransport
+			return
+		}
+
+		pem, err := ioutil.ReadFile(Conf.CaFile)
+		if err != nil {
+			transportErr = &Error{ConfigError, err}
+			return
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			transportErr = Errorf(ConfigError, "No certificates found in `%s`.", Conf.CaFile)
+			return
+		}
+
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.TLSClientConfig = &tls.Config{RootCAs: pool}
+		transport = t
+	})
+	return transport, transportErr
 }
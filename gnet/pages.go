@@ -0,0 +1,72 @@
+//
+// Copyright (c) 2019 Sony Mobile Communications Inc.
+// SPDX-License-Identifier: MIT
+//
+
+package gnet
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+)
+
+// nextLink matches the url of the next page in Link header of a response,
+// e.g. <https://api.github.com/...&page=2>; rel="next"
+var nextLink = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// GetPages gets all pages of a list of GitHub REST API v3, e.g.
+// repos/myorg/myrepo/issues?state=all&per_page=100, following
+// the `next` links of the responses, and returns the items of the list.
+func GetPages(query string) ([]json.RawMessage, error) {
+	items := make([]json.RawMessage, 0)
+	next := ""
+	for {
+		// a new request for every page, as the token may be refreshed
+		request, err := MakeGitHubV3Request(http.MethodGet, query)
+		if err != nil {
+			return nil, err
+		}
+		if next != "" {
+			request.Url = next
+		}
+
+		resp, status, err := request.Execute()
+		if err != nil {
+			return nil, err
+		}
+		if status.Code != http.StatusOK {
+			return nil, StatusError(status)
+		}
+
+		var page []json.RawMessage
+		if err = json.Unmarshal(resp, &page); err != nil {
+			return nil, &Error{DecodeError, err}
+		}
+		items = append(items, page...)
+
+		m := nextLink.FindStringSubmatch(status.Header.Get("Link"))
+		if m == nil {
+			return items, nil
+		}
+		next = m[1]
+		// don't give the token to other hosts
+		if err = sameHost(request.Url, next); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// sameHost checks that next url is on the same host as url.
+func sameHost(rawurl, next string) error {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return &Error{ConfigError, err}
+	}
+	n, err := url.Parse(next)
+	if err != nil || n.Scheme != u.Scheme || n.Host != u.Host {
+		return Errorf(DecodeError, "Unexpected next page `%s`.", next)
+	}
+	return nil
+}
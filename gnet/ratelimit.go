@@ -0,0 +1,207 @@
+//
+// Copyright (c) 2019 Sony Mobile Communications Inc.
+// SPDX-License-Identifier: MIT
+//
+
+package gnet
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	coreResource    = "core"
+	graphQlResource = "graphql"
+
+	// type of GraphQL error telling the rate limit is exhausted
+	rateLimitedError = "RATE_LIMITED"
+)
+
+var (
+	// MaxRetries is the number of times a request is repeated after
+	// a transient failure (502, 503, 504, secondary rate limit or
+	// RATE_LIMITED error of GraphQL API) before giving up and
+	// returning the last response.
+	MaxRetries = 5
+
+	// Progress reports waiting for rate limit reset or retry backoff
+	// on the progress line. Commands can replace it to fit their output.
+	Progress = func(msg string) {
+		fmt.Printf("\r%s", msg)
+	}
+
+	backoffBase          = time.Second
+	secondaryBackoffBase = time.Minute
+	limits               = &rateLimits{budgets: make(map[string]*budget)}
+)
+
+// budget holds the rate limit state of a single GitHub API resource
+// ("core" for REST API v3, "graphql" for GraphQL API v4) as last
+// reported by GitHub.
+type budget struct {
+	remaining int
+	cost      int
+	reset     time.Time
+}
+
+type rateLimits struct {
+	sync.Mutex
+	budgets map[string]*budget
+}
+
+// graphQlRateLimit is the `rateLimit { cost remaining resetAt }` object
+// queried along with the data in GraphQL API v4 requests.
+type graphQlRateLimit struct {
+	Data struct {
+		RateLimit *struct {
+			Cost      int       `json:"cost"`
+			Remaining int       `json:"remaining"`
+			ResetAt   time.Time `json:"resetAt"`
+		} `json:"rateLimit"`
+	} `json:"data"`
+}
+
+// wait blocks until the budget of resource is reset if the last
+// known state shows it can't cover the cost of another request.
+// The budget is shared by all requests, including concurrent ones.
+func (l *rateLimits) wait(resource string, progress func(msg string)) {
+	l.Lock()
+	b, ok := l.budgets[resource]
+	var d time.Duration
+	if ok && b.remaining < b.cost {
+		d = time.Until(b.reset)
+	}
+	l.Unlock()
+
+	if d > 0 {
+		progress(fmt.Sprintf("Rate limit exhausted, waiting %s until reset...",
+			d.Round(time.Second)))
+		time.Sleep(d)
+	}
+}
+
+// updateFromHeaders records X-RateLimit-Remaining and X-RateLimit-Reset
+// headers of a response.
+func (l *rateLimits) updateFromHeaders(resource string, h http.Header) {
+	remaining, err := strconv.Atoi(h.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	reset, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+	if r := h.Get("X-RateLimit-Resource"); r != "" {
+		resource = r
+	}
+
+	l.Lock()
+	defer l.Unlock()
+	b := l.get(resource)
+	b.remaining = remaining
+	b.reset = time.Unix(reset, 0)
+}
+
+// updateFromGraphQl records the rateLimit object of a GraphQL response
+// body, if present.
+func (l *rateLimits) updateFromGraphQl(body []byte) {
+	var rl graphQlRateLimit
+	if err := json.Unmarshal(body, &rl); err != nil || rl.Data.RateLimit == nil {
+		return
+	}
+
+	l.Lock()
+	defer l.Unlock()
+	b := l.get(graphQlResource)
+	b.remaining = rl.Data.RateLimit.Remaining
+	b.reset = rl.Data.RateLimit.ResetAt
+	if rl.Data.RateLimit.Cost > 0 {
+		b.cost = rl.Data.RateLimit.Cost
+	}
+}
+
+// get returns the budget of resource, creating it if needed.
+// Must be called with the lock held.
+func (l *rateLimits) get(resource string) *budget {
+	b, ok := l.budgets[resource]
+	if !ok {
+		b = &budget{cost: 1}
+		l.budgets[resource] = b
+	}
+	return b
+}
+
+// retryAfter decides whether a response with a given status, headers
+// and body should be retried and returns the time to wait before the
+// next attempt.
+func retryAfter(code int, h http.Header, body []byte, attempt int) (time.Duration, bool) {
+	// GraphQL API may answer even 200 OK with RATE_LIMITED error
+	if graphQlRateLimited(body) {
+		return untilReset(h, attempt), true
+	}
+
+	switch code {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return backoff(backoffBase, attempt), true
+	case http.StatusForbidden, http.StatusTooManyRequests:
+		// secondary rate limit tells explicitly how long to wait
+		if s, err := strconv.Atoi(h.Get("Retry-After")); err == nil {
+			return time.Duration(s) * time.Second, true
+		}
+		// primary rate limit exhausted, wait until reset
+		if h.Get("X-RateLimit-Remaining") == "0" && h.Get("X-RateLimit-Reset") != "" {
+			return untilReset(h, attempt), true
+		}
+		// secondary (abuse) rate limit without Retry-After header
+		msg := strings.ToLower(string(body))
+		if strings.Contains(msg, "abuse") || strings.Contains(msg, "secondary rate limit") {
+			return backoff(secondaryBackoffBase, attempt), true
+		}
+	}
+
+	return 0, false
+}
+
+// untilReset returns the time until X-RateLimit-Reset of headers,
+// or the backoff of secondary rate limit without the header. The reset
+// already passed, e.g. with skewed clocks, backs off from a second.
+func untilReset(h http.Header, attempt int) time.Duration {
+	reset, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return backoff(secondaryBackoffBase, attempt)
+	}
+	if d := time.Until(time.Unix(reset, 0)); d > 0 {
+		return d + time.Second
+	}
+	return backoff(backoffBase, attempt)
+}
+
+// graphQlRateLimited tells whether the body of GraphQL response has
+// RATE_LIMITED error, i.e. the rate limit of GraphQL API is exhausted.
+func graphQlRateLimited(body []byte) bool {
+	var resp struct {
+		Errors []struct {
+			Type string `json:"type"`
+		} `json:"errors"`
+	}
+	if len(body) == 0 || body[0] != '{' || json.Unmarshal(body, &resp) != nil {
+		return false
+	}
+	for _, e := range resp.Errors {
+		if e.Type == rateLimitedError {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns exponentially growing wait time for a given attempt.
+func backoff(base time.Duration, attempt int) time.Duration {
+	return base << uint(attempt)
+}
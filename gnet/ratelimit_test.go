@@ -0,0 +1,183 @@
+//
+// Copyright (c) 2019 Sony Mobile Communications Inc.
+// SPDX-License-Identifier: MIT
+//
+
+package gnet
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	reset := fmt.Sprintf("%d", time.Now().Add(time.Hour).Unix())
+	past := fmt.Sprintf("%d", time.Now().Add(-time.Hour).Unix())
+	rateLimited := `{"errors": [{"type": "RATE_LIMITED", "message": "API rate limit exceeded"}]}`
+
+	tests := []struct {
+		name    string
+		code    int
+		headers map[string]string
+		body    string
+		attempt int
+		min     time.Duration
+		max     time.Duration
+		retry   bool
+	}{
+		{"ok", http.StatusOK, nil, `{"data": {}}`, 0, 0, 0, false},
+		{"not found", http.StatusNotFound, nil, "", 0, 0, 0, false},
+		{"server error", http.StatusInternalServerError, nil, "", 0, 0, 0, false},
+		{"bad gateway", http.StatusBadGateway, nil, "", 0, backoffBase, backoffBase, true},
+		{"unavailable", http.StatusServiceUnavailable, nil, "", 2, 4 * backoffBase, 4 * backoffBase, true},
+		{"timeout", http.StatusGatewayTimeout, nil, "", 1, 2 * backoffBase, 2 * backoffBase, true},
+		{"retry after", http.StatusForbidden, map[string]string{"Retry-After": "30"}, "",
+			3, 30 * time.Second, 30 * time.Second, true},
+		{"too many", http.StatusTooManyRequests, map[string]string{"Retry-After": "5"}, "",
+			0, 5 * time.Second, 5 * time.Second, true},
+		// Retry-After goes first
+		{"retry after exhausted", http.StatusForbidden,
+			map[string]string{"Retry-After": "5", "X-RateLimit-Remaining": "0", "X-RateLimit-Reset": reset}, "",
+			0, 5 * time.Second, 5 * time.Second, true},
+		{"exhausted", http.StatusForbidden,
+			map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": reset}, "",
+			0, time.Hour - time.Minute, time.Hour + time.Second, true},
+		// skewed clock
+		{"exhausted reset passed", http.StatusForbidden,
+			map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": past}, "",
+			1, 2 * backoffBase, 2 * backoffBase, true},
+		// e.g. the token lacks permissions, not a rate limit
+		{"forbidden", http.StatusForbidden, map[string]string{"X-RateLimit-Remaining": "10"},
+			`{"message": "Resource not accessible"}`, 0, 0, 0, false},
+		{"abuse", http.StatusForbidden, nil, `{"message": "You have triggered an abuse detection mechanism."}`,
+			1, 2 * secondaryBackoffBase, 2 * secondaryBackoffBase, true},
+		{"secondary", http.StatusForbidden, nil, `{"message": "You have exceeded a secondary rate limit."}`,
+			0, secondaryBackoffBase, secondaryBackoffBase, true},
+		{"graphql", http.StatusOK, map[string]string{"X-RateLimit-Reset": reset}, rateLimited,
+			0, time.Hour - time.Minute, time.Hour + time.Second, true},
+		{"graphql without reset", http.StatusOK, nil, rateLimited,
+			2, 4 * secondaryBackoffBase, 4 * secondaryBackoffBase, true},
+		{"graphql other error", http.StatusOK, nil, `{"errors": [{"type": "NOT_FOUND"}]}`, 0, 0, 0, false},
+	}
+
+	for _, test := range tests {
+		h := make(http.Header)
+		for k, v := range test.headers {
+			h.Set(k, v)
+		}
+		wait, retry := retryAfter(test.code, h, []byte(test.body), test.attempt)
+		if retry != test.retry {
+			t.Errorf("%s: retry = %t, want %t", test.name, retry, test.retry)
+		}
+		if wait < test.min || wait > test.max {
+			t.Errorf("%s: wait = %s, want %s..%s", test.name, wait, test.min, test.max)
+		}
+	}
+}
+
+func TestGraphQlRateLimited(t *testing.T) {
+	tests := []struct {
+		body    string
+		limited bool
+	}{
+		{"", false},
+		{"not json", false},
+		{`[{"type": "RATE_LIMITED"}]`, false},
+		{`{"data": {"organization": null}}`, false},
+		{`{"errors": [{"type": "FORBIDDEN"}]}`, false},
+		{`{"errors": [{"type": "FORBIDDEN"}, {"type": "RATE_LIMITED"}]}`, true},
+	}
+	for _, test := range tests {
+		if limited := graphQlRateLimited([]byte(test.body)); limited != test.limited {
+			t.Errorf("graphQlRateLimited(%s) = %t, want %t", test.body, limited, test.limited)
+		}
+	}
+}
+
+func TestRateLimitsUpdate(t *testing.T) {
+	l := &rateLimits{budgets: make(map[string]*budget)}
+	reset := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	h := make(http.Header)
+	h.Set("X-RateLimit-Remaining", "42")
+	h.Set("X-RateLimit-Reset", fmt.Sprintf("%d", reset.Unix()))
+	// the resource of headers wins
+	h.Set("X-RateLimit-Resource", "search")
+	l.updateFromHeaders(coreResource, h)
+	if b := l.budgets["search"]; b == nil || b.remaining != 42 || !b.reset.Equal(reset) {
+		t.Errorf("search budget = %+v, want 42 until %s", b, reset)
+	}
+	if _, ok := l.budgets[coreResource]; ok {
+		t.Errorf("core budget updated from headers of search")
+	}
+
+	l.updateFromGraphQl([]byte(fmt.Sprintf(
+		`{"data": {"rateLimit": {"cost": 3, "remaining": 2, "resetAt": "%s"}}}`,
+		reset.UTC().Format(time.RFC3339))))
+	b := l.budgets[graphQlResource]
+	if b == nil || b.cost != 3 || b.remaining != 2 || !b.reset.Equal(reset) {
+		t.Errorf("graphql budget = %+v, want cost 3, 2 remaining until %s", b, reset)
+	}
+	// waiting for the reset
+	if b.remaining >= b.cost {
+		t.Errorf("graphql budget %+v covers another request", b)
+	}
+}
+
+func TestRunRetries(t *testing.T) {
+	base, secondary, retries := backoffBase, secondaryBackoffBase, MaxRetries
+	defer func() { backoffBase, secondaryBackoffBase, MaxRetries = base, secondary, retries }()
+	backoffBase, secondaryBackoffBase, MaxRetries = time.Millisecond, time.Millisecond, 3
+
+	var responses []func(w http.ResponseWriter)
+	attempts := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		responses[attempts](w)
+		attempts++
+	}))
+	defer s.Close()
+
+	status := func(code int) func(w http.ResponseWriter) {
+		return func(w http.ResponseWriter) { w.WriteHeader(code) }
+	}
+	body := func(b string) func(w http.ResponseWriter) {
+		return func(w http.ResponseWriter) { w.Write([]byte(b)) } // nolint
+	}
+	rateLimited := body(`{"errors": [{"type": "RATE_LIMITED"}]}`)
+	data := `{"data": {}}`
+
+	tests := []struct {
+		name      string
+		responses []func(w http.ResponseWriter)
+		code      int
+		body      string
+	}{
+		{"transient", []func(w http.ResponseWriter){status(502), status(503), body(data)}, 200, data},
+		{"rate limited", []func(w http.ResponseWriter){rateLimited, body(data)}, 200, data},
+		{"not retried", []func(w http.ResponseWriter){status(404)}, 404, ""},
+		{"gave up", []func(w http.ResponseWriter){status(502), status(502), status(502), status(502)},
+			502, ""},
+		// the body is left to the caller to report the errors
+		{"rate limited gave up", []func(w http.ResponseWriter){rateLimited, rateLimited, rateLimited,
+			rateLimited}, 200, `{"errors": [{"type": "RATE_LIMITED"}]}`},
+	}
+
+	for _, test := range tests {
+		responses, attempts = test.responses, 0
+		r := &Request{s.URL + v4Path, postMethod, nil, "{}", 5, func(msg string) {}}
+		b, st, err := r.Execute()
+		if err != nil {
+			t.Errorf("%s: failed with %s", test.name, err.Error())
+			continue
+		}
+		if st.Code != test.code || string(b) != test.body {
+			t.Errorf("%s: %d %q, want %d %q", test.name, st.Code, b, test.code, test.body)
+		}
+		if attempts != len(test.responses) {
+			t.Errorf("%s: %d attempts, want %d", test.name, attempts, len(test.responses))
+		}
+	}
+}
@@ -0,0 +1,197 @@
+//
+// Copyright (c) 2019 Sony Mobile Communications Inc.
+// SPDX-License-Identifier: MIT
+//
+
+package gnet
+
+import (
+	"ghorgs/utils"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// scopesHeader lists OAuth scopes of classic tokens in every response.
+const scopesHeader = "X-OAuth-Scopes"
+
+var (
+	// impliedScopes lists the scopes covered by a broader scope
+	// (see https://docs.github.com/en/apps/oauth-apps/building-oauth-apps/scopes-for-oauth-apps).
+	impliedScopes = map[string][]string{
+		"repo":             {"repo:status", "repo_deployment", "public_repo", "repo:invite", "security_events"},
+		"admin:org":        {"write:org", "read:org"},
+		"write:org":        {"read:org"},
+		"admin:repo_hook":  {"write:repo_hook", "read:repo_hook"},
+		"write:repo_hook":  {"read:repo_hook"},
+		"admin:public_key": {"write:public_key", "read:public_key"},
+		"write:public_key": {"read:public_key"},
+		"admin:gpg_key":    {"write:gpg_key", "read:gpg_key"},
+		"write:gpg_key":    {"read:gpg_key"},
+		"user":             {"read:user", "user:email", "user:follow"},
+	}
+
+	// appPermissions maps the scopes to permissions of GitHub App
+	// granting the same read access. Writes need more permissions than
+	// the scopes tell, which are given to CheckScopes by commands.
+	appPermissions = map[string]Permissions{
+		"repo":        {"contents": "read"},
+		"public_repo": {"contents": "read"},
+		"delete_repo": {"administration": "write"},
+		"read:org":    {"members": "read"},
+		"write:org":   {"members": "write"},
+		"admin:org":   {"members": "write"},
+	}
+
+	tokenScopes = &scopes{}
+)
+
+// Permissions of GitHub App by name, with their access level,
+// e.g. contents: write.
+type Permissions map[string]string
+
+// scopes of the token, queried once.
+type scopes struct {
+	sync.Mutex
+	token  string
+	scopes []string
+	known  bool
+}
+
+// CheckScopes checks that the token has the required scopes, so that
+// a command fails before it starts rather than in the middle of its work.
+// Scopes are read from X-OAuth-Scopes header of classic tokens, or
+// permissions of the installation of GitHub App are checked instead,
+// i.e. the ones matching the scopes and the given permissions.
+// Fine-grained tokens don't report their permissions and GitHub
+// Enterprise Server may not report scopes, so they are not checked.
+func CheckScopes(required []string, permissions Permissions) error {
+	token, err := Token()
+	if err != nil {
+		return err
+	}
+
+	if IsApp() {
+		if missing := missingPermissions(required, permissions); len(missing) > 0 {
+			return Errorf(AuthError, "Installation of GitHub App is missing required permissions: %s",
+				strings.Join(missing, ", "))
+		}
+		return nil
+	}
+
+	granted, known, err := tokenScopes.get(token)
+	if err != nil {
+		return err
+	}
+	if !known {
+		if utils.Debug.Verbose {
+			log.Printf("Token doesn't report its scopes, skipping check of: %s",
+				strings.Join(required, ", "))
+		}
+		return nil
+	}
+
+	if missing := missingScopes(granted, required); len(missing) > 0 {
+		return Errorf(AuthError, "Token is missing required scopes: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// get returns the scopes of the token and whether the token reports them.
+func (s *scopes) get(token string) ([]string, bool, error) {
+	s.Lock()
+	defer s.Unlock()
+	if s.token == token {
+		return s.scopes, s.known, nil
+	}
+
+	// the API root exists on GitHub Enterprise Server too, unlike
+	// e.g. rate_limit with rate limiting disabled
+	request, err := MakeGitHubV3Request(http.MethodGet, "")
+	if err != nil {
+		return nil, false, err
+	}
+	_, status, err := request.Execute()
+	if err != nil {
+		return nil, false, err
+	}
+	if status.Code != http.StatusOK && status.Code != http.StatusNotFound {
+		return nil, false, StatusError(status)
+	}
+
+	s.token = token
+	s.scopes = make([]string, 0)
+	values, known := status.Header[http.CanonicalHeaderKey(scopesHeader)]
+	s.known = known && status.Code == http.StatusOK
+	for _, v := range values {
+		for _, scope := range strings.Split(v, ",") {
+			if scope = strings.TrimSpace(scope); scope != "" {
+				s.scopes = append(s.scopes, scope)
+			}
+		}
+	}
+	return s.scopes, s.known, nil
+}
+
+// missingScopes returns the required scopes not covered by granted ones.
+func missingScopes(granted, required []string) []string {
+	covered := make(map[string]bool)
+	for _, scope := range granted {
+		covered[scope] = true
+		for _, implied := range impliedScopes[scope] {
+			covered[implied] = true
+			for _, sub := range impliedScopes[implied] {
+				covered[sub] = true
+			}
+		}
+	}
+
+	missing := make([]string, 0)
+	for _, scope := range required {
+		if !covered[scope] && !utils.StringInSlice(scope, missing) {
+			missing = append(missing, scope)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// missingPermissions returns the permissions of GitHub App matching
+// the required scopes and the given permissions, which are not granted
+// to its installation.
+func missingPermissions(required []string, permissions Permissions) []string {
+	appToken.Lock()
+	granted := appToken.permissions
+	appToken.Unlock()
+
+	// the highest access needed of each permission
+	needed := make(Permissions)
+	for name, access := range permissions {
+		needed[name] = access
+	}
+	for _, scope := range required {
+		for name, access := range appPermissions[scope] {
+			if !permits(needed[name], access) {
+				needed[name] = access
+			}
+		}
+	}
+
+	missing := make([]string, 0)
+	for name, access := range needed {
+		if !permits(granted[name], access) {
+			missing = append(missing, name+": "+access)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// permits tells whether the access level of a permission covers
+// the required level (admin > write > read).
+func permits(granted, required string) bool {
+	levels := map[string]int{"read": 1, "write": 2, "admin": 3}
+	return levels[granted] >= levels[required]
+}
@@ -0,0 +1,132 @@
+//
+// Copyright (c) 2019 Sony Mobile Communications Inc.
+// SPDX-License-Identifier: MIT
+//
+
+package gnet
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestCheckScopes(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("Authorization") {
+		case "bearer classic":
+			w.Header().Set(scopesHeader, "repo, read:org")
+		case "bearer empty":
+			w.Header().Set(scopesHeader, "")
+		case "bearer ghes":
+			// scopes of GitHub Enterprise Server without the API root
+			w.Header().Set(scopesHeader, "repo")
+			w.WriteHeader(http.StatusNotFound)
+		case "bearer invalid":
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+		// fine-grained tokens don't report scopes
+	}))
+	defer s.Close()
+	conf, cached := Conf, tokenScopes
+	defer func() { Conf, tokenScopes = conf, cached }()
+	Conf = gitHubConfiguration{Url: s.URL, TimeOut: 5}
+
+	tests := []struct {
+		token    string
+		required []string
+		kind     ErrorKind // 0 for no error
+	}{
+		{"classic", []string{"public_repo", "read:org"}, 0},
+		{"classic", []string{"repo", "delete_repo"}, AuthError},
+		{"empty", []string{"read:org"}, AuthError},
+		{"fine-grained", []string{"repo", "delete_repo"}, 0},
+		{"ghes", []string{"delete_repo"}, 0},
+		{"invalid", []string{"repo"}, AuthError},
+	}
+
+	for _, test := range tests {
+		Conf.Token = test.token
+		tokenScopes = &scopes{}
+		err := CheckScopes(test.required, nil)
+		if test.kind == 0 {
+			if err != nil {
+				t.Errorf("%s: CheckScopes(%v) failed: %s", test.token, test.required, err.Error())
+			}
+			continue
+		}
+		var gerr *Error
+		if !errors.As(err, &gerr) || gerr.Kind != test.kind {
+			t.Errorf("%s: CheckScopes(%v) failed with %v, want %s", test.token, test.required, err, test.kind)
+		}
+	}
+}
+
+func TestMissingScopes(t *testing.T) {
+	tests := []struct {
+		granted  []string
+		required []string
+		missing  []string
+	}{
+		{nil, nil, []string{}},
+		{[]string{"repo", "read:org"}, []string{"read:org", "repo"}, []string{}},
+		{nil, []string{"repo", "read:org", "repo"}, []string{"read:org", "repo"}},
+		// implied by broader ones
+		{[]string{"admin:org"}, []string{"read:org", "write:org"}, []string{}},
+		{[]string{"repo"}, []string{"public_repo", "repo:status"}, []string{}},
+		{[]string{"admin:repo_hook"}, []string{"read:repo_hook"}, []string{}},
+		// but not the other way round
+		{[]string{"read:org", "public_repo"}, []string{"admin:org", "repo"}, []string{"admin:org", "repo"}},
+		{[]string{"write:org"}, []string{"read:org", "admin:org"}, []string{"admin:org"}},
+		// delete_repo isn't part of repo
+		{[]string{"repo"}, []string{"delete_repo"}, []string{"delete_repo"}},
+	}
+
+	for _, test := range tests {
+		missing := missingScopes(test.granted, test.required)
+		if !reflect.DeepEqual(missing, test.missing) {
+			t.Errorf("missingScopes(%v, %v) = %v, want %v", test.granted, test.required, missing, test.missing)
+		}
+	}
+}
+
+func TestMissingPermissions(t *testing.T) {
+	appToken.Lock()
+	granted := appToken.permissions
+	appToken.permissions = map[string]string{"contents": "write", "members": "read", "issues": "admin"}
+	appToken.Unlock()
+	defer func() {
+		appToken.Lock()
+		appToken.permissions = granted
+		appToken.Unlock()
+	}()
+
+	tests := []struct {
+		required    []string
+		permissions Permissions
+		missing     []string
+	}{
+		{nil, nil, []string{}},
+		// read access by scopes
+		{[]string{"repo", "read:org"}, nil, []string{}},
+		{[]string{"admin:org"}, nil, []string{"members: write"}},
+		{[]string{"delete_repo"}, nil, []string{"administration: write"}},
+		// higher access levels cover lower ones
+		{nil, Permissions{"contents": "read", "issues": "write"}, []string{}},
+		{nil, Permissions{"contents": "admin"}, []string{"contents: admin"}},
+		// the highest access of scopes and permissions is needed
+		{[]string{"read:org"}, Permissions{"members": "write"}, []string{"members: write"}},
+		{[]string{"write:org"}, Permissions{"members": "read", "pull_requests": "read"},
+			[]string{"members: write", "pull_requests: read"}},
+	}
+
+	for _, test := range tests {
+		missing := missingPermissions(test.required, test.permissions)
+		if !reflect.DeepEqual(missing, test.missing) {
+			t.Errorf("missingPermissions(%v, %v) = %v, want %v",
+				test.required, test.permissions, missing, test.missing)
+		}
+	}
+}
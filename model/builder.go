@@ -0,0 +1,104 @@
+//
+// Copyright (c) 2019 Sony Mobile Communications Inc.
+// SPDX-License-Identifier: MIT
+//
+
+package model
+
+import (
+	"ghorgs/gnet"
+	"strings"
+	"text/template"
+)
+
+const organizationGraphQl = "organization.gql"
+
+// definition declares an entity queried as a connection of a GitHub
+// organization, e.g. organization.repositories. The GraphQL query, the
+// decoding of the response and the table are all derived from it.
+type definition struct {
+	name       string
+	csv        string
+	connection string // connection field of organization, e.g. "repositories"
+	nodes      string // "nodes" or "edges" list of the connection
+	id         string // path of the node's id
+	fields     []Field
+	fieldsOf   Fields // named access to fields, e.g. *RepositoryFields
+}
+
+// selection is a tree of GraphQL fields to select.
+type selection struct {
+	name     string
+	children []*selection
+}
+
+// add merges a path (split into segments) into the selection tree.
+func (s *selection) add(path []string) {
+	if len(path) == 0 {
+		return
+	}
+
+	for _, c := range s.children {
+		if c.name == path[0] {
+			c.add(path[1:])
+			return
+		}
+	}
+
+	c := &selection{name: path[0]}
+	s.children = append(s.children, c)
+	c.add(path[1:])
+}
+
+func (s *selection) write(b *strings.Builder, indent string) {
+	for _, c := range s.children {
+		b.WriteString(indent + c.name)
+		if len(c.children) > 0 {
+			b.WriteString(" {\n")
+			c.write(b, indent+"  ")
+			b.WriteString(indent + "}")
+		}
+		b.WriteString("\n")
+	}
+}
+
+// buildQuery creates the GraphQL query selecting the id and the given
+// fields of the entity's nodes. If fields is empty, all fields of the
+// entity are selected.
+func (d *definition) buildQuery(fields []Field) (string, error) {
+	if len(fields) == 0 {
+		fields = d.fields
+	}
+
+	root := &selection{}
+	root.add(splitPath(d.id))
+	for _, field := range fields {
+		if field.Path != "" {
+			root.add(splitPath(field.Path))
+		}
+	}
+
+	var sel strings.Builder
+	root.write(&sel, "        ")
+
+	text, err := queries.ReadFile("queries/" + organizationGraphQl)
+	if err != nil {
+		return "", &gnet.Error{Kind: gnet.ConfigError, Err: err}
+	}
+	tmpl, err := template.New(organizationGraphQl).Parse(string(text))
+	if err != nil {
+		return "", &gnet.Error{Kind: gnet.ConfigError, Err: err}
+	}
+
+	var query strings.Builder
+	err = tmpl.Execute(&query, struct {
+		Connection string
+		Nodes      string
+		Selection  string
+	}{d.connection, d.nodes, sel.String()})
+	if err != nil {
+		return "", &gnet.Error{Kind: gnet.ConfigError, Err: err}
+	}
+
+	return query.String(), nil
+}
@@ -0,0 +1,116 @@
+//
+// Copyright (c) 2019 Sony Mobile Communications Inc.
+// SPDX-License-Identifier: MIT
+//
+
+package model
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitPath(t *testing.T) {
+	tests := []struct {
+		path     string
+		segments []string
+	}{
+		{"name", []string{"name"}},
+		{"parentTeam.id", []string{"parentTeam", "id"}},
+		// dots in arguments don't split
+		{`node.repositories(query: "a.b", first: 1).totalCount`,
+			[]string{"node", `repositories(query: "a.b", first: 1)`, "totalCount"}},
+		{"a(x: f(y.z)).b", []string{"a(x: f(y.z))", "b"}},
+	}
+
+	for _, test := range tests {
+		if segments := splitPath(test.path); !reflect.DeepEqual(segments, test.segments) {
+			t.Errorf("splitPath(%s) = %q, want %q", test.path, segments, test.segments)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	var node map[string]interface{}
+	err := json.Unmarshal([]byte(`{"id": "u1", "node": {"login": "alice", "name": null,
+		"repositories": {"totalCount": 3}}}`), &node)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path  string
+		value interface{}
+	}{
+		{"id", "u1"},
+		{"node.login", "alice"},
+		// the key of a field with arguments is its name
+		{"node.repositories(affiliations: [OWNER]).totalCount", 3.0},
+		// null and missing
+		{"node.name", nil},
+		{"node.name.first", nil},
+		{"node.email", nil},
+		{"parent.id", nil},
+		// not an object
+		{"id.value", nil},
+	}
+
+	for _, test := range tests {
+		if v := lookup(node, test.path); !reflect.DeepEqual(v, test.value) {
+			t.Errorf("lookup(%s) = %v, want %v", test.path, v, test.value)
+		}
+	}
+}
+
+func TestBuildQuery(t *testing.T) {
+	fields := Users.GetFields().(*UsersFields)
+	query, err := usersDefinition.buildQuery([]Field{fields.Updated, fields.Admin,
+		fields.Login, fields.Organization})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the id first, paths under `node` merged, fields without path
+	// not queried
+	selection := `      edges {
+        node {
+          id
+          updatedAt
+          login
+        }
+        role
+      }
+`
+	if !strings.Contains(query, selection) {
+		t.Errorf("query doesn't select\n%s\nbut it's\n%s", selection, query)
+	}
+	if !strings.Contains(query, "membersWithRole(first: $first, after: $after) {") {
+		t.Errorf("query doesn't select membersWithRole connection:\n%s", query)
+	}
+	if strings.Contains(query, "{{") {
+		t.Errorf("query has unexpanded template:\n%s", query)
+	}
+}
+
+func TestBuildQueryAllFields(t *testing.T) {
+	query, err := usersDefinition.buildQuery(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range usersDefinition.fields {
+		if field.Path == "" {
+			continue
+		}
+		segments := splitPath(field.Path)
+		if last := segments[len(segments)-1]; !strings.Contains(query, last) {
+			t.Errorf("query doesn't select `%s` of %s:\n%s", last, field.Name, query)
+		}
+	}
+	args := "repositories(affiliations: [ORGANIZATION_MEMBER], " +
+		"ownerAffiliations: [ORGANIZATION_MEMBER, COLLABORATOR]) {"
+	if !strings.Contains(query, args) {
+		t.Errorf("query doesn't select `%s`:\n%s", args, query)
+	}
+}
@@ -0,0 +1,108 @@
+//
+// Copyright (c) 2019 Sony Mobile Communications Inc.
+// SPDX-License-Identifier: MIT
+//
+
+package model
+
+// FieldChange is a change of a single field of a record.
+type FieldChange struct {
+	Field  Field
+	Before string
+	After  string
+}
+
+// RecordChange lists the changed fields of a record present in both
+// compared tables.
+type RecordChange struct {
+	Key     string
+	Label   string // value of the first field, e.g. name of repository
+	Changes []FieldChange
+}
+
+// TableDiff is the difference between two tables of the same entity,
+// with records matched by key (Id).
+type TableDiff struct {
+	Fields  []Field // fields compared, i.e. those in both tables
+	Added   *Table  // records only in the newer table
+	Removed *Table  // records only in the older table
+	Changed []RecordChange
+	Skipped []string // names of fields only in one of the tables
+}
+
+// Empty reports whether the tables are the same.
+func (d *TableDiff) Empty() bool {
+	return len(d.Added.Keys) == 0 && len(d.Removed.Keys) == 0 && len(d.Changed) == 0
+}
+
+// Diff compares an older table to a newer one. Records are matched by
+// key and the fields present in both tables are compared by type, so
+// e.g. dates in different formats are equal if they are the same time.
+// Added and changed records are listed in order of the newer table,
+// removed ones in order of the older table.
+func Diff(older, newer *Table) *TableDiff {
+	fields := make([]Field, 0, len(newer.Fields))
+	skipped := make([]string, 0)
+	for _, f := range newer.Fields {
+		if older.setPivotField(f.Name) == nil {
+			fields = append(fields, f)
+		} else {
+			skipped = append(skipped, f.Name)
+		}
+	}
+	for _, f := range older.Fields {
+		if newer.setPivotField(f.Name) != nil {
+			skipped = append(skipped, f.Name)
+		}
+	}
+
+	// Project can't fail with fields of both tables
+	before, _ := older.Project(namesOf(fields))
+	after, _ := newer.Project(namesOf(fields))
+
+	d := &TableDiff{
+		Fields:  after.Fields,
+		Added:   MakeTable(newer.Fields),
+		Removed: MakeTable(older.Fields),
+		Changed: make([]RecordChange, 0),
+		Skipped: skipped,
+	}
+
+	for _, key := range newer.Keys {
+		old, ok := before.Records[key]
+		if !ok {
+			d.Added.AddKey(key)
+			d.Added.AddRecord(key, newer.Records[key])
+			continue
+		}
+
+		rc := RecordChange{Key: key, Label: label(newer, key)}
+		for i, field := range after.Fields {
+			a := after.Records[key][i]
+			if field.Compare(old[i], a) != 0 {
+				rc.Changes = append(rc.Changes, FieldChange{field, old[i], a})
+			}
+		}
+		if len(rc.Changes) > 0 {
+			d.Changed = append(d.Changed, rc)
+		}
+	}
+
+	for _, key := range older.Keys {
+		if _, ok := newer.Records[key]; !ok {
+			d.Removed.AddKey(key)
+			d.Removed.AddRecord(key, older.Records[key])
+		}
+	}
+
+	return d
+}
+
+// label returns the value of the first field of the record, which is
+// the name of the entity's node (e.g. Name of repository, Login of user).
+func label(t *Table, key string) string {
+	if len(t.Fields) == 0 {
+		return ""
+	}
+	return t.Records[key][0]
+}
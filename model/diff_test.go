@@ -0,0 +1,89 @@
+//
+// Copyright (c) 2019 Sony Mobile Communications Inc.
+// SPDX-License-Identifier: MIT
+//
+
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffSame(t *testing.T) {
+	d := Diff(reposTable(), reposTable())
+	if !d.Empty() {
+		t.Errorf("Diff of the same tables is not empty: %+v", d)
+	}
+	if len(d.Skipped) != 0 {
+		t.Errorf("Skipped = %v, want none", d.Skipped)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	fields := Repos.GetFields().(*RepositoryFields)
+	older := reposTable()
+	newer := reposTable()
+	// changed
+	newer.Records["id1"][fields.DiskUsage.Index] = "150"
+	// the same time in another format
+	newer.Records["id4"][fields.Updated.Index] = "2022-01-01 00:00:00 +0000 UTC"
+	// removed
+	newer.Keys = []string{"id1", "id3", "id4"}
+	delete(newer.Records, "id2")
+	// added
+	newer.AddKey("id5")
+	newer.AddRecord("id5", makeRecord(newer, map[int]string{
+		fields.Name.Index:         "epsilon",
+		fields.Type.Index:         "PUBLIC",
+		fields.DiskUsage.Index:    "1",
+		fields.Updated.Index:      "2023-01-01T00:00:00Z",
+		fields.Organization.Index: "o",
+	}))
+
+	d := Diff(older, newer)
+	if d.Empty() {
+		t.Fatal("Diff of different tables is empty")
+	}
+	if !reflect.DeepEqual(d.Added.Keys, []string{"id5"}) {
+		t.Errorf("Added = %v, want [id5]", d.Added.Keys)
+	}
+	if !reflect.DeepEqual(d.Removed.Keys, []string{"id2"}) {
+		t.Errorf("Removed = %v, want [id2]", d.Removed.Keys)
+	}
+	if len(d.Changed) != 1 {
+		t.Fatalf("Changed = %+v, want a change of id1", d.Changed)
+	}
+	rc := d.Changed[0]
+	if rc.Key != "id1" || rc.Label != "alpha" || len(rc.Changes) != 1 {
+		t.Fatalf("Changed = %+v, want a change of DiskUsage of id1", rc)
+	}
+	fc := rc.Changes[0]
+	if fc.Field.Name != "DiskUsage (kB)" || fc.Before != "100" || fc.After != "150" {
+		t.Errorf("Change = %+v, want DiskUsage (kB) 100 -> 150", fc)
+	}
+}
+
+func TestDiffFields(t *testing.T) {
+	older, err := reposTable().Project([]string{"Name", "DiskUsage (kB)", "Url"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := Repos.GetFields().(*RepositoryFields)
+	newer := reposTable()
+	newer.Records["id1"][fields.DiskUsage.Index] = "150"
+	// not compared
+	newer.Records["id2"][fields.Archived.Index] = "false"
+
+	d := Diff(older, newer)
+	if names := namesOf(d.Fields); !reflect.DeepEqual(names, []string{"Id", "Name", "Url", "DiskUsage (kB)"}) {
+		t.Errorf("Fields = %v, want fields of both tables in order of the newer one", names)
+	}
+	skipped := []string{"Type", "Updated", "Last Push", "Archived", "Organization"}
+	if !reflect.DeepEqual(d.Skipped, skipped) {
+		t.Errorf("Skipped = %v, want %v", d.Skipped, skipped)
+	}
+	if len(d.Changed) != 1 || d.Changed[0].Key != "id1" {
+		t.Errorf("Changed = %+v, want a change of id1", d.Changed)
+	}
+}
@@ -7,28 +7,36 @@ package model
 
 import (
 	"fmt"
+	"ghorgs/utils"
+	"sort"
 	"strings"
 )
 
-// Entity interface represents methods to create and
-// execute query and create and execute a response to
-// that query
+// Entity describes an entity of an organization (repositories,
+// members, teams...): its fields, how to query them and how to decode
+// the responses. Entities are immutable and hold no response state, so
+// the same Entity can be fetched any number of times, concurrently,
+// e.g. for several organizations. Each response is decoded into a new Page:
+//
+//	q, err := entity.MakeQuery(org, fields)
+//	for {
+//	    // execute q.GetGraphQlJson() as GitHub GraphQL API v4 request
+//	    page, err := entity.ParsePage(org, body)
+//	    page.AppendTable(table)
+//	    if !page.HasNext {
+//	        break
+//	    }
+//	    err = q.GetNext(page.Cursor)
+//	}
 type Entity interface {
 	GetName() string
-
-	MakeTable() *Table
-	AppendTable(c *Table)
+	GetCsvFile() string
 	GetFields() Fields
 	HasField(s string) bool
-	GetCsvFile() string
 
-	MakeQuery(org string) Query
-	FromJsonBuffer(buff []byte)
-	GetTotal() int
-	HasNext() bool
-	GetNext() string
-
-	String() string
+	MakeTable() *Table
+	MakeQuery(org string, fields []Field) (Query, error)
+	ParsePage(org string, buff []byte) (*Page, error)
 }
 
 var (
@@ -39,15 +47,15 @@ var (
 	//
 	// for all entities allowed to be used from interactive commands.
 	EntityMap map[string]Entity
-	Repos     *ReposResponse
-	Users     *UsersResponse
-	Teams     *TeamsResponse
+	Repos     *Descriptor
+	Users     *Descriptor
+	Teams     *Descriptor
 )
 
 func init() {
-	Repos = &ReposResponse{}
-	Users = &UsersResponse{}
-	Teams = &TeamsResponse{}
+	Repos = &Descriptor{reposDefinition}
+	Users = &Descriptor{usersDefinition}
+	Teams = &Descriptor{teamsDefinition}
 	EntityMap = map[string]Entity{
 		Repos.GetName(): Repos,
 		Users.GetName(): Users,
@@ -55,7 +63,44 @@ func init() {
 	}
 }
 
-// EntityNamesList returns the list of names of entities
+// Descriptor implements Entity for an entity given by its definition.
+type Descriptor struct {
+	def *definition
+}
+
+func (d *Descriptor) GetName() string {
+	return d.def.name
+}
+
+func (d *Descriptor) GetCsvFile() string {
+	return d.def.csv
+}
+
+func (d *Descriptor) GetFields() Fields {
+	return d.def.fieldsOf
+}
+
+func (d *Descriptor) HasField(s string) bool {
+	return utils.StringInSlice(s, namesOf(d.def.fields))
+}
+
+func (d *Descriptor) MakeTable() *Table {
+	return MakeTable(d.def.fields)
+}
+
+func (d *Descriptor) MakeQuery(org string, fields []Field) (Query, error) {
+	q, err := makeQuery(d.def, org, fields)
+	if err != nil {
+		return nil, err
+	}
+	return &q, nil
+}
+
+func (d *Descriptor) ParsePage(org string, buff []byte) (*Page, error) {
+	return parsePage(d.def, org, buff)
+}
+
+// EntityNamesList returns the sorted list of names of entities
 // (use e.g. to dump the list)
 func EntityNamesList() []string {
 	return keysOf(EntityMap)
@@ -67,8 +112,8 @@ func EntityNamesList() []string {
 func ValidateEntities(e string) ([]Entity, error) {
 	var activeEntities = make([]Entity, 0, len(EntityMap))
 	if e == "" || e == "all" {
-		for _, entity := range EntityMap {
-			activeEntities = append(activeEntities, entity)
+		for _, name := range EntityNamesList() {
+			activeEntities = append(activeEntities, EntityMap[name])
 		}
 	} else {
 		var slices = strings.Split(e, ",")
@@ -103,10 +148,43 @@ func ValidateEntityField(field string, activeEntities []Entity) error {
 	return nil
 }
 
+// ValidateSortKeys parses a list of sort keys (see ParseSortKeys) and
+// checks that their fields exist in the list of activeEntities.
+func ValidateSortKeys(by string, activeEntities []Entity) ([]SortKey, error) {
+	keys, err := ParseSortKeys(by)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+		if err = ValidateEntityField(key.Field, activeEntities); err != nil {
+			return nil, err
+		}
+	}
+
+	return keys, nil
+}
+
+// ValidateEntityFields checks that each of the fields exists in the list
+// of activeEntities.
+func ValidateEntityFields(fields []string, activeEntities []Entity) error {
+	for _, field := range fields {
+		if field == "" {
+			return fmt.Errorf("Empty field name in the list of fields.\n")
+		}
+		if err := ValidateEntityField(field, activeEntities); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func keysOf(m map[string]Entity) []string {
 	keys := make([]string, 0, len(m))
 	for key := range m {
 		keys = append(keys, key)
 	}
+	sort.Strings(keys)
 	return keys
 }
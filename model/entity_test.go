@@ -0,0 +1,90 @@
+//
+// Copyright (c) 2019 Sony Mobile Communications Inc.
+// SPDX-License-Identifier: MIT
+//
+
+package model
+
+import (
+	"encoding/json"
+	"ghorgs/gnet"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// variables returns the variables of the json body of a query.
+func variables(t *testing.T, q Query) map[string]interface{} {
+	var r graphQlRequest
+	if err := json.Unmarshal([]byte(q.GetGraphQlJson()), &r); err != nil {
+		t.Fatal(err)
+	}
+	return r.Variables
+}
+
+func TestMakeQuery(t *testing.T) {
+	// queries are generated, not read from user's configuration
+	dir, err := ioutil.TempDir("", "ghorgs-queries-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	conf := gnet.Conf
+	defer func() { gnet.Conf = conf }()
+	gnet.Conf.QueriesDir = dir
+	gnet.Conf.PerPage = 50
+
+	// queries of the same entity don't share any state
+	qa, err := Repos.MakeQuery("a", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	qb, err := Repos.MakeQuery("b", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = qa.GetNext("cursor"); err != nil {
+		t.Fatal(err)
+	}
+
+	va, vb := variables(t, qa), variables(t, qb)
+	if va["org"] != "a" || va["first"] != 50.0 || va["after"] != "cursor" {
+		t.Errorf("variables of the next page of a = %v", va)
+	}
+	if vb["org"] != "b" || vb["after"] != nil {
+		t.Errorf("variables of the first page of b = %v", vb)
+	}
+
+	gnet.Conf.Organization = ""
+	if _, err = Repos.MakeQuery("", nil); err == nil {
+		t.Errorf("MakeQuery without organization didn't fail")
+	}
+}
+
+func TestMakeQueryOverride(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ghorgs-queries-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	conf := gnet.Conf
+	defer func() { gnet.Conf = conf }()
+	gnet.Conf.QueriesDir = dir
+
+	custom := "query ($org: String!) { organization(login: $org) { id } }"
+	if err = ioutil.WriteFile(filepath.Join(dir, Teams.GetName()+".gql"), []byte(custom), 0644); err != nil {
+		t.Fatal(err)
+	}
+	q, err := Teams.MakeQuery("a", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r graphQlRequest
+	if err = json.Unmarshal([]byte(q.GetGraphQlJson()), &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Query != custom {
+		t.Errorf("query = %q, want the one of queries_dir", r.Query)
+	}
+}
@@ -5,11 +5,48 @@
 
 package model
 
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Field is a column of a Table together with its GraphQL definition,
+// so that the query, the decoding of the response and the table row
+// are all derived from the same declaration.
 type Field struct {
 	Name  string
 	Index int
+	Type  FieldType
+	// Path is the GraphQL path of the field relative to a node of the
+	// entity's connection, with segments separated by dots, e.g.
+	// "node.repositories(affiliations: [ORGANIZATION_MEMBER]).totalCount".
+	// Fields without Path are not queried (see OrganizationName).
+	Path string
+	// Value converts the decoded json value at Path to a table cell.
+	// If nil, the value is converted with timeValue for TimeType fields
+	// and with defaultValue otherwise.
+	Value func(v interface{}) string
 }
 
+// FieldType determines how values of a field are compared
+// (and represented in typed output formats).
+type FieldType int
+
+const (
+	StringType FieldType = iota
+	IntType
+	BoolType
+	TimeType // cells are normalized to RFC3339
+)
+
+// OrganizationName is the name of the field of all entities holding
+// the organization of the record, which is not queried but set from the
+// organization of the page (see Page.AppendTable).
+const OrganizationName = "Organization"
+
 type Fields interface {
 	asList() []Field
 	DisplayNames() []string
@@ -23,8 +60,15 @@ var (
 	// "Id" field itself is a key for that record
 	// (and not part of the records slice), so we
 	// give "Id" index -1 by convention.
-	INVALID_FIELD = Field{"INVALID_FIELD", -2}
-	ID            = Field{"Id", -1}
+	INVALID_FIELD = Field{Name: "INVALID_FIELD", Index: -2}
+	ID            = Field{Name: "Id", Index: -1}
+
+	// timeLayouts are accepted when parsing dates, e.g. in --since flag,
+	// filters or dumps written by older versions of ghorgs.
+	timeLayouts = []string{time.RFC3339,
+		"2006-01-02",
+		"2006-1-2",
+		"2006-01-02 15:04:05 -0700 MST"}
 )
 
 func namesOf(fields []Field) []string {
@@ -35,3 +79,197 @@ func namesOf(fields []Field) []string {
 	}
 	return names
 }
+
+// extract returns the table cell of the field from a decoded node.
+func (f Field) extract(node map[string]interface{}) string {
+	v := lookup(node, f.Path)
+	if f.Value != nil {
+		return f.Value(v)
+	}
+	if f.Type == TimeType {
+		return timeValue(v)
+	}
+	return defaultValue(v)
+}
+
+// lookup walks a decoded json node along a GraphQL path and returns
+// the value found (or nil if any part of the path is null).
+func lookup(node map[string]interface{}, path string) interface{} {
+	var v interface{} = node
+	for _, segment := range splitPath(path) {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[jsonKey(segment)]
+	}
+	return v
+}
+
+// splitPath splits a GraphQL path to segments on dots which are
+// not part of field arguments.
+func splitPath(path string) []string {
+	segments := make([]string, 0)
+	depth := 0
+	start := 0
+	for i, c := range path {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case '.':
+			if depth == 0 {
+				segments = append(segments, path[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(segments, path[start:])
+}
+
+// jsonKey returns the key of a path segment in the json response,
+// i.e. the field name without arguments.
+func jsonKey(segment string) string {
+	if i := strings.Index(segment, "("); i >= 0 {
+		segment = segment[:i]
+	}
+	return strings.TrimSpace(segment)
+}
+
+// defaultValue converts json values to strings: null to empty string
+// and numbers and booleans to their literal representation.
+func defaultValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case json.Number:
+		return val.String()
+	case bool:
+		return fmt.Sprintf("%t", val)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// timeValue converts a GraphQL DateTime to a table cell in RFC3339.
+// Null is converted to empty string.
+func timeValue(v interface{}) string {
+	s, ok := v.(string)
+	if !ok {
+		return ""
+	}
+	t, err := ParseTime(s)
+	if err != nil {
+		return s
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// ParseTime parses a date in RFC3339 or YYYY-MM-DD format, the month
+// and day possibly without leading zero.
+func ParseTime(s string) (time.Time, error) {
+	var err error
+	for _, layout := range timeLayouts {
+		var t time.Time
+		t, err = time.Parse(layout, s)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// Compare compares two cells of the field according to its type and
+// returns -1, 0 or 1. Empty cells are less than any other value and
+// cells which can't be parsed as the field's type are compared
+// as strings.
+func (f Field) Compare(a, b string) int {
+	return f.Type.Compare(a, b)
+}
+
+// Compare compares two values of the type, see Field.Compare.
+func (t FieldType) Compare(a, b string) int {
+	if a == "" || b == "" {
+		return strings.Compare(a, b)
+	}
+
+	switch t {
+	case IntType:
+		fa, erra := strconv.ParseFloat(a, 64)
+		fb, errb := strconv.ParseFloat(b, 64)
+		if erra == nil && errb == nil {
+			return compareFloats(fa, fb)
+		}
+	case BoolType:
+		ba, erra := strconv.ParseBool(a)
+		bb, errb := strconv.ParseBool(b)
+		if erra == nil && errb == nil {
+			switch {
+			case ba == bb:
+				return 0
+			case bb:
+				return -1
+			}
+			return 1
+		}
+	case TimeType:
+		ta, erra := ParseTime(a)
+		tb, errb := ParseTime(b)
+		if erra == nil && errb == nil {
+			switch {
+			case ta.Before(tb):
+				return -1
+			case ta.After(tb):
+				return 1
+			}
+			return 0
+		}
+	}
+
+	return strings.Compare(a, b)
+}
+
+// Validate checks that value can be parsed as the type.
+func (t FieldType) Validate(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	var err error
+	switch t {
+	case IntType:
+		_, err = strconv.ParseFloat(value, 64)
+	case BoolType:
+		_, err = strconv.ParseBool(value)
+	case TimeType:
+		_, err = ParseTime(value)
+	}
+	if err != nil {
+		return fmt.Errorf("`%s` is not a valid %s", value, t)
+	}
+	return nil
+}
+
+func (t FieldType) String() string {
+	switch t {
+	case IntType:
+		return "number"
+	case BoolType:
+		return "boolean"
+	case TimeType:
+		return "date"
+	}
+	return "string"
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,443 @@
+//
+// Copyright (c) 2019 Sony Mobile Communications Inc.
+// SPDX-License-Identifier: MIT
+//
+
+package model
+
+import (
+	"fmt"
+	"ghorgs/utils"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Filter is a predicate over Table records parsed from an expression like:
+//
+//	DiskUsage > 100000 && Type == "PRIVATE" && Name =~ "^legacy-"
+//
+// Expressions are comparisons of fields and literals combined with
+// `&&`, `||`, `!` and parentheses. Comparison operators are
+// `==`, `!=`, `<`, `<=`, `>`, `>=`, `=~` (matches regular expression)
+// and `!~` (doesn't match regular expression). Literals are strings in
+// double quotes, numbers, `true` and `false`. Fields are compared with
+// literals by type, e.g. `Updated < "2020-01-01"` compares dates.
+//
+// Fields are referred to by name, in which spaces and units in parentheses
+// can be left out (e.g. `DiskUsage` for "DiskUsage (kB)", `LastPush` for
+// "Last Push"), or by full name in backticks (e.g. `Last Push`).
+type Filter struct {
+	expr   string
+	root   node
+	fields []string
+}
+
+// node of the parsed expression tree
+type node interface {
+	eval(key string, record []string) bool
+}
+
+type andNode struct{ left, right node }
+type orNode struct{ left, right node }
+type notNode struct{ operand node }
+
+type comparisonNode struct {
+	op          string
+	left, right operand
+	re          *regexp.Regexp
+}
+
+// operand is either a field (index >= ID.Index) or a literal
+type operand struct {
+	field   Field
+	literal string
+	isField bool
+}
+
+func (n *andNode) eval(key string, record []string) bool {
+	return n.left.eval(key, record) && n.right.eval(key, record)
+}
+
+func (n *orNode) eval(key string, record []string) bool {
+	return n.left.eval(key, record) || n.right.eval(key, record)
+}
+
+func (n *notNode) eval(key string, record []string) bool {
+	return !n.operand.eval(key, record)
+}
+
+func (o operand) value(key string, record []string) string {
+	if !o.isField {
+		return o.literal
+	}
+	if o.field.Index == ID.Index {
+		return key
+	}
+	return record[o.field.Index]
+}
+
+func (n *comparisonNode) eval(key string, record []string) bool {
+	left := n.left.value(key, record)
+	right := n.right.value(key, record)
+
+	switch n.op {
+	case "=~":
+		return n.re.MatchString(left)
+	case "!~":
+		return !n.re.MatchString(left)
+	}
+
+	c := n.compare(left, right)
+	switch n.op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+// compare compares the values by type of the field operand. Literals
+// are compared numerically if both are numbers and as strings otherwise.
+func (n *comparisonNode) compare(a, b string) int {
+	if n.left.isField {
+		return n.left.field.Compare(a, b)
+	}
+	if n.right.isField {
+		return n.right.field.Compare(a, b)
+	}
+
+	fa, erra := strconv.ParseFloat(a, 64)
+	fb, errb := strconv.ParseFloat(b, 64)
+	if erra == nil && errb == nil {
+		return compareFloats(fa, fb)
+	}
+	return strings.Compare(a, b)
+}
+
+// ParseFilter parses the expression expr into a Filter over records of
+// a table with the given fields.
+func ParseFilter(expr string, fields []Field) (*Filter, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid filter `%s`: %s", expr, err.Error())
+	}
+
+	p := &parser{tokens: tokens, fields: fields}
+	root, err := p.parseOr()
+	if err == nil && p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected `%s`", p.tokens[p.pos].text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Invalid filter `%s`: %s", expr, err.Error())
+	}
+
+	return &Filter{expr, root, p.used}, nil
+}
+
+// Match reports whether the record with a given key satisfies the filter.
+func (f *Filter) Match(key string, record []string) bool {
+	return f.root.eval(key, record)
+}
+
+// FieldNames returns the names of the fields used in the filter.
+func (f *Filter) FieldNames() []string {
+	return f.fields
+}
+
+func (f *Filter) String() string {
+	return f.expr
+}
+
+// Where returns a copy of the table with the records satisfying
+// the filter expression.
+func (t *Table) Where(expr string) (*Table, error) {
+	f, err := ParseFilter(expr, t.Fields)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.Filter(f), nil
+}
+
+// Filter returns a copy of the table with the records satisfying f.
+func (t *Table) Filter(f *Filter) *Table {
+	ret := MakeTable(t.Fields)
+	for _, key := range t.Keys {
+		if f.Match(key, t.Records[key]) {
+			ret.AddKey(key)
+			ret.AddRecord(key, t.Records[key])
+		}
+	}
+
+	return ret
+}
+
+type tokenKind int
+
+const (
+	identToken tokenKind = iota
+	stringToken
+	numberToken
+	opToken
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "=~", "!~", "<", ">", "!", "(", ")"}
+
+func tokenize(expr string) ([]token, error) {
+	tokens := make([]token, 0)
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"':
+			j := i + 1
+			for ; j < len(runes) && runes[j] != '"'; j++ {
+				if runes[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			s, err := strconv.Unquote(string(runes[i : j+1]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid string %s", string(runes[i:j+1]))
+			}
+			tokens = append(tokens, token{stringToken, s})
+			i = j + 1
+		case c == '`':
+			j := i + 1
+			for ; j < len(runes) && runes[j] != '`'; j++ {
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("unterminated field name")
+			}
+			tokens = append(tokens, token{identToken, string(runes[i+1 : j])})
+			i = j + 1
+		case isWordRune(c) || c == '-' || c == '.':
+			j := i + 1
+			for ; j < len(runes) && (isWordRune(runes[j]) || runes[j] == '.'); j++ {
+			}
+			word := string(runes[i:j])
+			if _, err := strconv.ParseFloat(word, 64); err == nil {
+				tokens = append(tokens, token{numberToken, word})
+			} else if c == '-' || c == '.' {
+				return nil, fmt.Errorf("invalid number `%s`", word)
+			} else {
+				tokens = append(tokens, token{identToken, word})
+			}
+			i = j
+		default:
+			op := ""
+			for _, o := range operators {
+				if strings.HasPrefix(string(runes[i:]), o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character `%c`", c)
+			}
+			tokens = append(tokens, token{opToken, op})
+			i += len([]rune(op))
+		}
+	}
+
+	return tokens, nil
+}
+
+func isWordRune(c rune) bool {
+	return c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	fields []Field
+	used   []string
+}
+
+func (p *parser) peek() *token {
+	if p.pos < len(p.tokens) {
+		return &p.tokens[p.pos]
+	}
+	return nil
+}
+
+func (p *parser) acceptOp(op string) bool {
+	if t := p.peek(); t != nil && t.kind == opToken && t.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptOp("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptOp("&&") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.acceptOp("!") {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand}, nil
+	}
+	if p.acceptOp("(") {
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.acceptOp(")") {
+			return nil, fmt.Errorf("missing `)`")
+		}
+		return n, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	t := p.peek()
+	if t == nil || t.kind != opToken {
+		return nil, fmt.Errorf("expected comparison operator after `%s`", p.tokens[p.pos-1].text)
+	}
+	op := t.text
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=", "=~", "!~":
+		p.pos++
+	default:
+		return nil, fmt.Errorf("expected comparison operator, found `%s`", op)
+	}
+
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	n := &comparisonNode{op: op, left: left, right: right}
+	if op == "=~" || op == "!~" {
+		if right.isField {
+			return nil, fmt.Errorf("regular expression must be a string literal")
+		}
+		n.re, err = regexp.Compile(right.literal)
+		if err != nil {
+			return nil, err
+		}
+		return n, nil
+	}
+
+	// literals compared with a field must be valid values of its type
+	if left.isField && !right.isField {
+		err = left.field.Type.Validate(right.literal)
+	} else if right.isField && !left.isField {
+		err = right.field.Type.Validate(left.literal)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+func (p *parser) parseOperand() (operand, error) {
+	t := p.peek()
+	if t == nil {
+		return operand{}, fmt.Errorf("unexpected end of expression")
+	}
+	p.pos++
+
+	switch t.kind {
+	case stringToken, numberToken:
+		return operand{literal: t.text}, nil
+	case identToken:
+		if t.text == "true" || t.text == "false" {
+			return operand{literal: t.text}, nil
+		}
+		field, err := p.resolve(t.text)
+		if err != nil {
+			return operand{}, err
+		}
+		return operand{field: field, isField: true}, nil
+	}
+
+	return operand{}, fmt.Errorf("unexpected `%s`", t.text)
+}
+
+// resolve finds the field referred to by name: by its exact name or
+// by its short name (see Filter).
+func (p *parser) resolve(name string) (Field, error) {
+	candidates := append([]Field{ID}, p.fields...)
+	for _, exact := range []bool{true, false} {
+		for _, field := range candidates {
+			if (exact && field.Name == name) ||
+				(!exact && shortName(field.Name) == strings.ToLower(name)) {
+				if !utils.StringInSlice(field.Name, p.used) {
+					p.used = append(p.used, field.Name)
+				}
+				return field, nil
+			}
+		}
+	}
+
+	return INVALID_FIELD, fmt.Errorf("unknown field `%s`. Choose one of: %s",
+		name, strings.Join(namesOf(p.fields), ", "))
+}
+
+// shortName returns lower case name of field without spaces and
+// units in parentheses, e.g. "diskusage" for "DiskUsage (kB)".
+func shortName(name string) string {
+	if i := strings.Index(name, "("); i >= 0 {
+		name = name[:i]
+	}
+	return strings.ToLower(strings.Join(strings.Fields(name), ""))
+}
@@ -0,0 +1,194 @@
+//
+// Copyright (c) 2019 Sony Mobile Communications Inc.
+// SPDX-License-Identifier: MIT
+//
+
+package model
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// reposTable returns a table of repositories:
+//
+//	Id   Name          Type     DiskUsage Updated    Last Push  Archived
+//	id1  alpha         PUBLIC   100       2020-01-01 2019-06-01 false
+//	id2  beta          PRIVATE  2000      2021-05-01 2021-04-01 true
+//	id3  legacy-gamma  PRIVATE  50        2018-03-01            false
+//	id4  delta         PUBLIC   0         2022-01-01 2022-01-01 false
+//
+// all with Url after the name and Organization o.
+func reposTable() *Table {
+	f := Repos.GetFields().(*RepositoryFields)
+	t := Repos.MakeTable()
+	for _, r := range []struct {
+		id, name, typ, size, updated, pushed, archived string
+	}{
+		{"id1", "alpha", "PUBLIC", "100", "2020-01-01T00:00:00Z", "2019-06-01T00:00:00Z", "false"},
+		{"id2", "beta", "PRIVATE", "2000", "2021-05-01T00:00:00Z", "2021-04-01T00:00:00Z", "true"},
+		{"id3", "legacy-gamma", "PRIVATE", "50", "2018-03-01T00:00:00Z", "", "false"},
+		{"id4", "delta", "PUBLIC", "0", "2022-01-01T00:00:00Z", "2022-01-01T00:00:00Z", "false"},
+	} {
+		t.AddKey(r.id)
+		t.AddRecord(r.id, makeRecord(t, map[int]string{
+			f.Name.Index:         r.name,
+			f.Type.Index:         r.typ,
+			f.Url.Index:          "https://github.com/o/" + r.name,
+			f.DiskUsage.Index:    r.size,
+			f.Updated.Index:      r.updated,
+			f.LastPush.Index:     r.pushed,
+			f.Archived.Index:     r.archived,
+			f.Organization.Index: "o",
+		}))
+	}
+	return t
+}
+
+// makeRecord returns a record of table t with the given cells by index
+// of their fields, so that fixtures don't depend on the order of fields.
+func makeRecord(t *Table, cells map[int]string) []string {
+	record := make([]string, len(t.Fields))
+	for index, value := range cells {
+		record[index] = value
+	}
+	return record
+}
+
+func TestWhere(t *testing.T) {
+	tests := []struct {
+		expr string
+		keys []string
+	}{
+		// comparisons by type of the field
+		{`Name == "alpha"`, []string{"id1"}},
+		{`Name != "alpha"`, []string{"id2", "id3", "id4"}},
+		{`DiskUsage > 99`, []string{"id1", "id2"}},
+		{`DiskUsage >= 100`, []string{"id1", "id2"}},
+		{`DiskUsage <= 50`, []string{"id3", "id4"}},
+		{`Updated < "2020-06-01"`, []string{"id1", "id3"}},
+		{`Archived == true`, []string{"id2"}},
+		{`Archived != false`, []string{"id2"}},
+		{`Id == "id3"`, []string{"id3"}},
+		{`Name =~ "^legacy-"`, []string{"id3"}},
+		{`Name !~ "^legacy-"`, []string{"id1", "id2", "id4"}},
+		// empty cells are less than any value
+		{`LastPush < "2020-01-01"`, []string{"id1", "id3"}},
+		// negative numbers, on either side
+		{`DiskUsage > -1`, []string{"id1", "id2", "id3", "id4"}},
+		{`-1 < DiskUsage`, []string{"id1", "id2", "id3", "id4"}},
+		{`DiskUsage > -1.5e3`, []string{"id1", "id2", "id3", "id4"}},
+		// names in backticks, short names in any case
+		{"`Last Push` >= \"2021-01-01\"", []string{"id2", "id4"}},
+		{"`DiskUsage (kB)` == 0", []string{"id4"}},
+		{`lastpush >= "2021-01-01"`, []string{"id2", "id4"}},
+		{`diskusage == 0`, []string{"id4"}},
+		// && binds tighter than ||
+		{`Name == "alpha" || Name == "beta" && Archived == true`, []string{"id1", "id2"}},
+		{`Archived == true && Name == "beta" || Name == "delta"`, []string{"id2", "id4"}},
+		{`(Name == "alpha" || Name == "beta") && Archived == true`, []string{"id2"}},
+		// ! binds tighter than && and ||
+		{`!(Archived == true)`, []string{"id1", "id3", "id4"}},
+		{`! Name =~ "^legacy-" && DiskUsage < 1000`, []string{"id1", "id4"}},
+		{`!Name == "alpha" || Name == "alpha"`, []string{"id1", "id2", "id3", "id4"}},
+		{`!!(Type == "PRIVATE")`, []string{"id2", "id3"}},
+		{`Type == "PRIVATE" && !(DiskUsage < 100)`, []string{"id2"}},
+	}
+
+	for _, test := range tests {
+		result, err := reposTable().Where(test.expr)
+		if err != nil {
+			t.Errorf("Where(%s) failed: %s", test.expr, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(result.Keys, test.keys) {
+			t.Errorf("Where(%s) = %v, want %v", test.expr, result.Keys, test.keys)
+		}
+	}
+}
+
+func TestWhereTwoFactor(t *testing.T) {
+	f := Users.GetFields().(*UsersFields)
+	users := Users.MakeTable()
+	users.AddKey("u1")
+	users.AddRecord("u1", makeRecord(users, map[int]string{
+		f.Login.Index: "alice", f.Admin.Index: "ADMIN", f.MFA.Index: "true", f.Repositories.Index: "3"}))
+	users.AddKey("u2")
+	users.AddRecord("u2", makeRecord(users, map[int]string{
+		f.Login.Index: "bob", f.Admin.Index: "MEMBER", f.MFA.Index: "false", f.Repositories.Index: "5"}))
+
+	tests := []struct {
+		expr string
+		keys []string
+	}{
+		// the name starts with a digit, but it's not a number
+		{`2FA == false`, []string{"u2"}},
+		{"`2FA` == true", []string{"u1"}},
+		{`2fa == true && AccessibleRepositories > 2`, []string{"u1"}},
+	}
+
+	for _, test := range tests {
+		result, err := users.Where(test.expr)
+		if err != nil {
+			t.Errorf("Where(%s) failed: %s", test.expr, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(result.Keys, test.keys) {
+			t.Errorf("Where(%s) = %v, want %v", test.expr, result.Keys, test.keys)
+		}
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		err  string
+	}{
+		// literals are validated by type of the field
+		{`DiskUsage > "big"`, "`big` is not a valid number"},
+		{`"big" < DiskUsage`, "`big` is not a valid number"},
+		{`Archived == "yes"`, "`yes` is not a valid boolean"},
+		{`Updated < "yesterday"`, "`yesterday` is not a valid"},
+		// tokens
+		{`Name == "alpha`, "unterminated string"},
+		{"`Last Push > 1", "unterminated field name"},
+		{`DiskUsage > -x`, "invalid number `-x`"},
+		{`Name # 1`, "unexpected character `#`"},
+		// syntax
+		{`Size > 1`, "unknown field `Size`. Choose one of: Id, Name, Type"},
+		{`Name == "alpha" &&`, "unexpected end of expression"},
+		{`(Name == "alpha"`, "missing `)`"},
+		{`Name "alpha"`, "expected comparison operator after `Name`"},
+		{`Name && Type`, "expected comparison operator, found `&&`"},
+		{`Name == "alpha" Name`, "unexpected `Name`"},
+		{`Name == )`, "unexpected `)`"},
+		{`Name =~ Type`, "regular expression must be a string literal"},
+		{`Name =~ "("`, "error parsing regexp"},
+	}
+
+	fields := Repos.MakeTable().Fields
+	for _, test := range tests {
+		_, err := ParseFilter(test.expr, fields)
+		if err == nil {
+			t.Errorf("ParseFilter(%s) didn't fail", test.expr)
+			continue
+		}
+		prefix := "Invalid filter `" + test.expr + "`: "
+		if !strings.HasPrefix(err.Error(), prefix) || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("ParseFilter(%s) failed with %q, want %q", test.expr, err.Error(), prefix+test.err)
+		}
+	}
+}
+
+func TestFilterFieldNames(t *testing.T) {
+	f, err := ParseFilter(`DiskUsage > 1 && lastpush < "2020-01-01" || diskusage < 5`,
+		Repos.MakeTable().Fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"DiskUsage (kB)", "Last Push"}
+	if !reflect.DeepEqual(f.FieldNames(), want) {
+		t.Errorf("FieldNames() = %v, want %v", f.FieldNames(), want)
+	}
+}
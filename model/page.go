@@ -0,0 +1,161 @@
+//
+// Copyright (c) 2019 Sony Mobile Communications Inc.
+// SPDX-License-Identifier: MIT
+//
+
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"ghorgs/gnet"
+	"strings"
+)
+
+const (
+	forbiddenError = "FORBIDDEN"
+	notFoundError  = "NOT_FOUND"
+)
+
+// QueryError is a single error from the `errors` array of a GraphQL
+// API v4 response. GitHub reports e.g. permission problems and SAML
+// enforcement this way while still returning HTTP 200.
+type QueryError struct {
+	Message string        `json:"message"`
+	Type    string        `json:"type"`
+	Path    []interface{} `json:"path"` // elements are field names or list indices
+}
+
+func (e QueryError) Error() string {
+	s := e.Message
+	if e.Type != "" {
+		s += " (type: " + e.Type + ")"
+	}
+	if len(e.Path) > 0 {
+		s += " at " + e.PathString()
+	}
+	return s
+}
+
+// PathString returns the path of the error in dot notation,
+// e.g. organization.membersWithRole.edges.3.node.email
+func (e QueryError) PathString() string {
+	parts := make([]string, 0, len(e.Path))
+	for _, p := range e.Path {
+		parts = append(parts, fmt.Sprintf("%v", p))
+	}
+	return strings.Join(parts, ".")
+}
+
+// IsSaml reports whether the error is caused by the organization's SAML SSO
+// enforcement, i.e. the token is not authorized for the organization.
+func (e QueryError) IsSaml() bool {
+	return strings.Contains(e.Message, "SAML")
+}
+
+// Page is a single page of an entity's connection decoded from
+// a response to the entity's query (see Entity).
+type Page struct {
+	Organization string                   // login of the organization
+	Nodes        []map[string]interface{} // nodes (or edges) as generic json objects
+	HasNext      bool                     // there are more pages
+	Cursor       string                   // end cursor, see Query.GetNext
+	Total        int                      // total count of nodes of the connection
+	Errors       []QueryError             // errors reported in the response
+	id           string                   // path of the node's id
+}
+
+// response is the GraphQL API v4 response to a query of an entity
+type response struct {
+	Errors []QueryError `json:"errors,omitempty"`
+	Data   struct {
+		Organization map[string]*connection `json:"organization"`
+	} `json:"data"`
+}
+
+// connection is the decoded connection of an organization,
+// with nodes kept as generic json objects.
+type connection struct {
+	Nodes    []map[string]interface{} `json:"nodes"`
+	Edges    []map[string]interface{} `json:"edges"`
+	PageInfo struct {
+		HasNext bool   `json:"hasNextPage"`
+		End     string `json:"endCursor"`
+	} `json:"pageInfo"`
+	Total int `json:"totalCount"`
+}
+
+func parsePage(def *definition, org string, buff []byte) (*Page, error) {
+	var r response
+	// keep numbers as they are instead of converting them to float64
+	decoder := json.NewDecoder(bytes.NewReader(buff))
+	decoder.UseNumber()
+	if err := decoder.Decode(&r); err != nil {
+		return nil, &gnet.Error{Kind: gnet.DecodeError, Err: err}
+	}
+
+	p := &Page{Organization: org, Errors: r.Errors, id: def.id}
+	if c, ok := r.Data.Organization[def.connection]; ok && c != nil {
+		p.Nodes = c.Nodes
+		if def.nodes == "edges" {
+			p.Nodes = c.Edges
+		}
+		p.HasNext = c.PageInfo.HasNext
+		p.Cursor = c.PageInfo.End
+		p.Total = c.Total
+	}
+	return p, nil
+}
+
+// AppendTable appends a record for each node of the page to the table,
+// extracting the values of the table's fields. The Organization field
+// is set to the organization of the page.
+func (p *Page) AppendTable(t *Table) {
+	for _, node := range p.Nodes {
+		id := defaultValue(lookup(node, p.id))
+		record := make([]string, len(t.Fields))
+		for i, field := range t.Fields {
+			if field.Name == OrganizationName {
+				record[i] = p.Organization
+				continue
+			}
+			record[i] = field.extract(node)
+		}
+		t.AddKey(id)
+		t.AddRecord(id, record)
+	}
+}
+
+// CheckErrors splits the errors reported in the response into warnings,
+// which only affect parts of the data (e.g. a field of a single node),
+// and an error, which means that data for the entity is missing
+// altogether (organization not found, SAML enforcement, invalid query...).
+func (p *Page) CheckErrors() ([]QueryError, error) {
+	var warnings []QueryError
+	for _, qe := range p.Errors {
+		// errors at `organization` or `organization.<connection>`
+		// leave the whole entity empty
+		if !qe.IsSaml() && len(qe.Path) > 2 {
+			warnings = append(warnings, qe)
+			continue
+		}
+
+		switch {
+		case qe.IsSaml():
+			return warnings, gnet.Errorf(gnet.AuthError,
+				"%s\nThe token must be authorized for SAML single sign-on of the organization "+
+					"(see https://docs.github.com/en/github/authenticating-to-github/"+
+					"authorizing-a-personal-access-token-for-use-with-saml-single-sign-on).",
+				qe.Message)
+		case qe.Type == forbiddenError:
+			return warnings, &gnet.Error{Kind: gnet.AuthError, Err: qe}
+		case qe.Type == notFoundError:
+			return warnings, &gnet.Error{Kind: gnet.NotFoundError, Err: qe}
+		default:
+			return warnings, &gnet.Error{Kind: gnet.ConfigError, Err: qe}
+		}
+	}
+
+	return warnings, nil
+}
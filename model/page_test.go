@@ -0,0 +1,141 @@
+//
+// Copyright (c) 2019 Sony Mobile Communications Inc.
+// SPDX-License-Identifier: MIT
+//
+
+package model
+
+import (
+	"errors"
+	"ghorgs/gnet"
+	"reflect"
+	"testing"
+)
+
+func TestParsePage(t *testing.T) {
+	body := `{"data": {"organization": {"membersWithRole": {
+		"pageInfo": {"hasNextPage": true, "endCursor": "c1"},
+		"totalCount": 3,
+		"edges": [
+			{"role": "ADMIN", "hasTwoFactorEnabled": true, "node": {"id": "u1", "login": "alice",
+				"name": null, "updatedAt": "2020-01-01T01:00:00+01:00",
+				"repositories": {"totalCount": 12345678901}}},
+			{"role": "MEMBER", "hasTwoFactorEnabled": null, "node": {"id": "u2", "login": "bob"}}
+		]}}}}`
+
+	page, err := Users.ParsePage("o", []byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !page.HasNext || page.Cursor != "c1" || page.Total != 3 || len(page.Nodes) != 2 {
+		t.Errorf("page = %+v, want 2 of 3 nodes with next page after c1", page)
+	}
+
+	table := Users.MakeTable()
+	page.AppendTable(table)
+	if !reflect.DeepEqual(table.Keys, []string{"u1", "u2"}) {
+		t.Fatalf("keys = %v, want [u1 u2]", table.Keys)
+	}
+	fields := Users.GetFields().(*UsersFields)
+	for _, test := range []struct {
+		key   string
+		field Field
+		value string
+	}{
+		{"u1", fields.Login, "alice"},
+		// null
+		{"u1", fields.Name, ""},
+		{"u1", fields.Admin, "ADMIN"},
+		{"u1", fields.MFA, "true"},
+		// converted to UTC
+		{"u1", fields.Updated, "2020-01-01T00:00:00Z"},
+		// numbers are kept as they are
+		{"u1", fields.Repositories, "12345678901"},
+		{"u1", fields.Organization, "o"},
+		{"u2", fields.MFA, ""},
+		// missing
+		{"u2", fields.Updated, ""},
+	} {
+		if v := table.Records[test.key][test.field.Index]; v != test.value {
+			t.Errorf("%s of %s = %q, want %q", test.field.Name, test.key, v, test.value)
+		}
+	}
+}
+
+func TestParsePageErrors(t *testing.T) {
+	_, err := Repos.ParsePage("o", []byte(`{"data": `))
+	var gerr *gnet.Error
+	if !errors.As(err, &gerr) || gerr.Kind != gnet.DecodeError {
+		t.Errorf("ParsePage of truncated body failed with %v, want DecodeError", err)
+	}
+
+	// no data at all
+	page, err := Repos.ParsePage("o", []byte(`{"data": {"organization": null},
+		"errors": [{"type": "NOT_FOUND", "path": ["organization"], "message": "Could not resolve"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Nodes) != 0 || page.HasNext || len(page.Errors) != 1 {
+		t.Errorf("page = %+v, want no nodes and an error", page)
+	}
+}
+
+func TestCheckErrors(t *testing.T) {
+	saml := QueryError{Message: "Resource protected by organization SAML enforcement.",
+		Type: forbiddenError, Path: []interface{}{"organization"}}
+	forbidden := QueryError{Message: "Resource not accessible by integration",
+		Type: forbiddenError, Path: []interface{}{"organization", "membersWithRole"}}
+	notFound := QueryError{Message: "Could not resolve to an Organization",
+		Type: notFoundError, Path: []interface{}{"organization"}}
+	// e.g. invalid custom query
+	invalid := QueryError{Message: "Field 'size' doesn't exist on type 'Repository'"}
+	// a field of a single node
+	email := QueryError{Message: "Email is hidden", Type: forbiddenError,
+		Path: []interface{}{"organization", "membersWithRole", "edges", 3.0, "node", "email"}}
+	// SAML is an error anywhere
+	deepSaml := QueryError{Message: "Resource protected by organization SAML enforcement.",
+		Path: []interface{}{"organization", "repositories", "nodes", 0.0, "name"}}
+
+	tests := []struct {
+		name     string
+		errors   []QueryError
+		warnings int
+		kind     gnet.ErrorKind // 0 for no error
+	}{
+		{"none", nil, 0, 0},
+		{"saml", []QueryError{saml}, 0, gnet.AuthError},
+		{"deep saml", []QueryError{email, deepSaml}, 1, gnet.AuthError},
+		{"forbidden", []QueryError{forbidden}, 0, gnet.AuthError},
+		{"not found", []QueryError{notFound}, 0, gnet.NotFoundError},
+		{"invalid", []QueryError{invalid}, 0, gnet.ConfigError},
+		{"partial data", []QueryError{email, email}, 2, 0},
+		{"partial data first", []QueryError{email, notFound}, 1, gnet.NotFoundError},
+	}
+
+	for _, test := range tests {
+		p := &Page{Errors: test.errors}
+		warnings, err := p.CheckErrors()
+		if len(warnings) != test.warnings {
+			t.Errorf("%s: %d warnings, want %d", test.name, len(warnings), test.warnings)
+		}
+		if test.kind == 0 {
+			if err != nil {
+				t.Errorf("%s: failed with %s", test.name, err.Error())
+			}
+			continue
+		}
+		var gerr *gnet.Error
+		if !errors.As(err, &gerr) || gerr.Kind != test.kind {
+			t.Errorf("%s: failed with %v, want %s", test.name, err, test.kind)
+		}
+	}
+}
+
+func TestQueryErrorString(t *testing.T) {
+	e := QueryError{Message: "Email is hidden", Type: forbiddenError,
+		Path: []interface{}{"organization", "membersWithRole", "edges", 3.0, "node", "email"}}
+	want := "Email is hidden (type: FORBIDDEN) at organization.membersWithRole.edges.3.node.email"
+	if e.Error() != want {
+		t.Errorf("Error() = %q, want %q", e.Error(), want)
+	}
+}
@@ -6,40 +6,102 @@
 package model
 
 import (
-	"fmt"
+	"embed"
+	"encoding/json"
 	"ghorgs/gnet"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 )
 
+// queries holds the GraphQL query template of organization's connections,
+// in which the selection of fields is generated from entity definitions.
+// Queries take variables $org (organization login), $first (page size)
+// and $after (cursor of the previous page, null for the first page).
+//
+//go:embed queries/*.gql
+var queries embed.FS
+
 type QueryBase struct {
 	Organization     string
 	Count            int
+	GraphQlQuery     string
+	After            string
 	GraphQlQueryJson string
 }
 
 type Query interface {
 	GetGraphQlJson() string
-	GetNext(after string)
+	GetNext(after string) error
 	GetCount() int
 }
 
-func makeQuery(jsonFile string, organization string) QueryBase {
+// graphQlRequest is the json body of a GraphQL API v4 request.
+type graphQlRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+func makeQuery(def *definition, organization string, fields []Field) (QueryBase, error) {
 	if organization == "" {
 		organization = gnet.Conf.Organization
 	}
 
-	if gnet.Conf.Organization == "" {
-		panic("Missing GitHub Organization.")
+	if organization == "" {
+		return QueryBase{}, gnet.Errorf(gnet.ConfigError, "Missing GitHub Organization.")
+	}
+
+	query, err := loadQuery(def.name + ".gql")
+	if err != nil {
+		return QueryBase{}, err
+	}
+	if query == "" {
+		query, err = def.buildQuery(fields)
+		if err != nil {
+			return QueryBase{}, err
+		}
+	}
+
+	q := QueryBase{Organization: organization,
+		Count:        gnet.Conf.PerPage,
+		GraphQlQuery: query}
+	return q, q.makeJson()
+}
+
+// loadQuery returns the query named gqlFile from the directory given
+// by `queries_dir` configuration, which overrides the query generated
+// from the entity definition. If there is no such file, it returns
+// an empty string.
+func loadQuery(gqlFile string) (string, error) {
+	dir := QueriesDir()
+	if dir == "" {
+		return "", nil
 	}
 
-	bytes, err := ioutil.ReadFile(jsonFile)
+	bytes, err := ioutil.ReadFile(filepath.Join(dir, gqlFile))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
 	if err != nil {
-		panic(err)
+		return "", &gnet.Error{Kind: gnet.ConfigError, Err: err}
+	}
+	return string(bytes), nil
+}
+
+// QueriesDir returns the directory with user's queries overriding
+// the generated ones: `queries_dir` from configuration,
+// or `ghorgs/queries` in the user's configuration directory
+// (e.g. ~/.config/ghorgs/queries on Linux).
+func QueriesDir() string {
+	if gnet.Conf.QueriesDir != "" {
+		return gnet.Conf.QueriesDir
 	}
 
-	return QueryBase{organization,
-		gnet.Conf.PerPage,
-		fmt.Sprintf(string(bytes), organization, gnet.Conf.PerPage)}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, gnet.ConfigDir, "queries")
 }
 
 func (q *QueryBase) GetCount() int {
@@ -50,11 +112,28 @@ func (q *QueryBase) GetGraphQlJson() string {
 	return q.GraphQlQueryJson
 }
 
-func (q *QueryBase) getNext(jsonFile string, after string) {
-	bytes, err := ioutil.ReadFile(jsonFile)
+func (q *QueryBase) GetNext(after string) error {
+	q.After = after
+	return q.makeJson()
+}
+
+// makeJson creates the json body of the request from the query template
+// and the values of its variables.
+func (q *QueryBase) makeJson() error {
+	variables := map[string]interface{}{
+		"org":   q.Organization,
+		"first": q.Count,
+		"after": nil,
+	}
+	if q.After != "" {
+		variables["after"] = q.After
+	}
+
+	bytes, err := json.Marshal(graphQlRequest{q.GraphQlQuery, variables})
 	if err != nil {
-		panic(err)
+		return &gnet.Error{Kind: gnet.ConfigError, Err: err}
 	}
 
-	q.GraphQlQueryJson = fmt.Sprintf(string(bytes), q.Organization, q.Count, after)
+	q.GraphQlQueryJson = string(bytes)
+	return nil
 }
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"log"
 	"sort"
+	"strings"
 )
 
 type Table struct {
@@ -60,34 +61,121 @@ func (t *Table) String() string {
 	return s
 }
 
+// Project returns a copy of the table with only the given fields,
+// in the given order. "Id" is the key of the table, so it is always
+// kept as the first column and skipped if listed in fieldNames.
+func (t *Table) Project(fieldNames []string) (*Table, error) {
+	fields := make([]Field, 0, len(fieldNames))
+	indices := make([]int, 0, len(fieldNames))
+	for _, name := range fieldNames {
+		if name == ID.Name {
+			continue
+		}
+		if err := t.setPivotField(name); err != nil {
+			return nil, err
+		}
+		field := t.pivotField
+		indices = append(indices, field.Index)
+		field.Index = len(fields)
+		fields = append(fields, field)
+	}
+
+	ret := MakeTable(fields)
+	for _, key := range t.Keys {
+		record := make([]string, len(indices))
+		for i, index := range indices {
+			record[i] = t.Records[key][index]
+		}
+		ret.AddKey(key)
+		ret.AddRecord(key, record)
+	}
+
+	return ret, nil
+}
+
 func (t *Table) Log() {
 	log.Println(t)
 }
 
-// sort interface + method
-type By Table
+// SortKey is a field to sort a table by, in ascending
+// or descending order.
+type SortKey struct {
+	Field      string
+	Descending bool
+}
 
-func (a By) Len() int { return len(a.Keys) }
-func (a By) Less(i, j int) bool {
-	if a.pivotField.Index == ID.Index {
-		return a.Keys[i] < a.Keys[j]
+// ParseSortKeys parses a comma separated list of field names, each
+// optionally followed by `:asc` or `:desc`, e.g. "DiskUsage (kB):desc,Name".
+func ParseSortKeys(s string) ([]SortKey, error) {
+	keys := make([]SortKey, 0)
+	if s == "" {
+		return keys, nil
 	}
-	return a.Records[a.Keys[i]][a.pivotField.Index] < a.Records[a.Keys[j]][a.pivotField.Index]
-}
-func (a By) Swap(i, j int) {
-	a.Keys[i], a.Keys[j] = a.Keys[j], a.Keys[i]
+
+	for _, item := range strings.Split(s, ",") {
+		key := SortKey{Field: item}
+		if i := strings.LastIndex(item, ":"); i >= 0 {
+			key.Field = item[:i]
+			switch strings.ToLower(item[i+1:]) {
+			case "asc":
+			case "desc":
+				key.Descending = true
+			default:
+				return nil, fmt.Errorf("Invalid sort order `%s` of `%s`. Use `asc` or `desc`.\n",
+					item[i+1:], key.Field)
+			}
+		}
+		if key.Field == "" {
+			return nil, fmt.Errorf("Empty field name in `%s`.\n", s)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
 }
 
-// sort by field ascending
+// sort by field ascending, comparing values by type of the field
 func (t *Table) SortByField(field string) (*Table, error) {
-	err := t.setPivotField(field)
-	if err != nil {
-		return nil, err
+	return t.SortBy(SortKey{Field: field})
+}
+
+// SortBy sorts the table by given keys: by the first key and, among records
+// with equal values of the first key, by the second key etc. Values are
+// compared by type of the field. The sort is stable, so records equal
+// in all the keys keep their order. The table is sorted in place and
+// returned for convenience.
+func (t *Table) SortBy(keys ...SortKey) (*Table, error) {
+	fields := make([]Field, 0, len(keys))
+	for _, key := range keys {
+		err := t.setPivotField(key.Field)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, t.pivotField)
 	}
 
-	tt := *t
-	sort.Sort(By(tt))
-	return &tt, nil
+	sort.SliceStable(t.Keys, func(i, j int) bool {
+		for k, field := range fields {
+			c := field.Compare(t.cell(t.Keys[i], field), t.cell(t.Keys[j], field))
+			if keys[k].Descending {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+
+	return t, nil
+}
+
+// cell returns the value of field in the record with a given key.
+func (t *Table) cell(key string, field Field) string {
+	if field.Index == ID.Index {
+		return key
+	}
+	return t.Records[key][field.Index]
 }
 
 // gets all records with field equals value
@@ -99,7 +187,7 @@ func (t *Table) FindAllByField(field, val string) (*Table, error) {
 
 	var ret *Table
 	for _, key := range t.Keys {
-		if t.Records[key][t.pivotField.Index] == val {
+		if t.pivotField.Compare(t.Records[key][t.pivotField.Index], val) == 0 {
 			if ret == nil {
 				ret = MakeTable(t.Fields)
 			}
@@ -158,15 +246,21 @@ func (t *Table) FindAllByFieldValues(field string, vals []string) (*Table, error
 	return ret, nil
 }
 
+// LessThanByField returns records with the field less than val,
+// comparing values by type of the field (e.g. dates as dates).
+// Val which is not a valid value of the type is an error.
 func (t *Table) LessThanByField(field, val string) (*Table, error) {
 	err := t.setPivotField(field)
 	if err != nil {
 		return nil, err
 	}
+	if err = t.pivotField.Type.Validate(val); err != nil {
+		return nil, fmt.Errorf("Cannot compare `%s`: %s.", field, err.Error())
+	}
 
 	ret := MakeTable(t.Fields)
 	for _, key := range t.Keys {
-		if t.Records[key][t.pivotField.Index] < val {
+		if t.pivotField.Compare(t.Records[key][t.pivotField.Index], val) < 0 {
 			ret.AddKey(key)
 			ret.AddRecord(key, t.Records[key])
 		}
@@ -175,15 +269,20 @@ func (t *Table) LessThanByField(field, val string) (*Table, error) {
 	return ret, nil
 }
 
+// GreaterThanByField returns records with the field greater than val,
+// comparing values by type of the field (e.g. dates as dates).
 func (t *Table) GreaterThanByField(field, val string) (*Table, error) {
 	err := t.setPivotField(field)
 	if err != nil {
 		return nil, err
 	}
+	if err = t.pivotField.Type.Validate(val); err != nil {
+		return nil, fmt.Errorf("Cannot compare `%s`: %s.", field, err.Error())
+	}
 
 	ret := MakeTable(t.Fields)
 	for _, key := range t.Keys {
-		if t.Records[key][t.pivotField.Index] > val {
+		if t.pivotField.Compare(t.Records[key][t.pivotField.Index], val) > 0 {
 			ret.AddKey(key)
 			ret.AddRecord(key, t.Records[key])
 		}
@@ -236,7 +335,7 @@ func (t *Table) setPivotField(fieldName string) error {
 		}
 	}
 
-	if t.pivotField == INVALID_FIELD {
+	if t.pivotField.Index == INVALID_FIELD.Index {
 		return fmt.Errorf("Invalid search field: %s\n", fieldName)
 	}
 
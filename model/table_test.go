@@ -0,0 +1,123 @@
+//
+// Copyright (c) 2019 Sony Mobile Communications Inc.
+// SPDX-License-Identifier: MIT
+//
+
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestThanByField(t *testing.T) {
+	tests := []struct {
+		less bool
+		val  string
+		keys []string
+	}{
+		{true, "2020-06-01", []string{"id1", "id3"}},
+		// month and day without leading zero
+		{true, "2020-6-1", []string{"id1", "id3"}},
+		{false, "2021-1-5", []string{"id2", "id4"}},
+		{false, "2021-01-05T00:00:00Z", []string{"id2", "id4"}},
+	}
+
+	for _, test := range tests {
+		var result *Table
+		var err error
+		if test.less {
+			result, err = reposTable().LessThanByField("Updated", test.val)
+		} else {
+			result, err = reposTable().GreaterThanByField("Updated", test.val)
+		}
+		if err != nil {
+			t.Errorf("ThanByField(%s) failed: %s", test.val, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(result.Keys, test.keys) {
+			t.Errorf("ThanByField(%s) = %v, want %v", test.val, result.Keys, test.keys)
+		}
+	}
+}
+
+func TestThanByFieldErrors(t *testing.T) {
+	for _, val := range []string{"yesterday", "2020-02-30", "2020/01/01"} {
+		if _, err := reposTable().LessThanByField("Updated", val); err == nil {
+			t.Errorf("LessThanByField(%s) didn't fail", val)
+		}
+		if _, err := reposTable().GreaterThanByField("Updated", val); err == nil {
+			t.Errorf("GreaterThanByField(%s) didn't fail", val)
+		}
+	}
+	if _, err := reposTable().LessThanByField("DiskUsage (kB)", "big"); err == nil {
+		t.Errorf("LessThanByField(big) didn't fail")
+	}
+}
+
+func TestParseSortKeys(t *testing.T) {
+	tests := []struct {
+		by   string
+		keys []SortKey
+	}{
+		{"", []SortKey{}},
+		{"Name", []SortKey{{"Name", false}}},
+		{"DiskUsage (kB):desc,Name", []SortKey{{"DiskUsage (kB)", true}, {"Name", false}}},
+		{"Name:ASC,Updated:Desc", []SortKey{{"Name", false}, {"Updated", true}}},
+	}
+	for _, test := range tests {
+		keys, err := ParseSortKeys(test.by)
+		if err != nil {
+			t.Errorf("ParseSortKeys(%s) failed: %s", test.by, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(keys, test.keys) {
+			t.Errorf("ParseSortKeys(%s) = %v, want %v", test.by, keys, test.keys)
+		}
+	}
+
+	for _, by := range []string{"Name:up", ":desc", "Name,,Type"} {
+		if _, err := ParseSortKeys(by); err == nil {
+			t.Errorf("ParseSortKeys(%s) didn't fail", by)
+		}
+	}
+}
+
+func TestSortBy(t *testing.T) {
+	tests := []struct {
+		by   string
+		keys []string
+	}{
+		// numbers, not strings, i.e. 2000 > 100
+		{"DiskUsage (kB)", []string{"id4", "id3", "id1", "id2"}},
+		{"DiskUsage (kB):desc", []string{"id2", "id1", "id3", "id4"}},
+		// empty cells first
+		{"Last Push", []string{"id3", "id1", "id2", "id4"}},
+		{"Id:desc", []string{"id4", "id3", "id2", "id1"}},
+		// stable, i.e. equal records keep their order
+		{"Type", []string{"id2", "id3", "id1", "id4"}},
+		{"Archived", []string{"id1", "id3", "id4", "id2"}},
+		// multiple keys
+		{"Type:desc,Updated:desc", []string{"id4", "id1", "id2", "id3"}},
+		{"Archived,Type,Name:desc", []string{"id3", "id4", "id1", "id2"}},
+	}
+
+	for _, test := range tests {
+		keys, err := ParseSortKeys(test.by)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := reposTable().SortBy(keys...)
+		if err != nil {
+			t.Errorf("SortBy(%s) failed: %s", test.by, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(result.Keys, test.keys) {
+			t.Errorf("SortBy(%s) = %v, want %v", test.by, result.Keys, test.keys)
+		}
+	}
+
+	if _, err := reposTable().SortBy(SortKey{Field: "Size"}); err == nil {
+		t.Errorf("SortBy(Size) didn't fail")
+	}
+}
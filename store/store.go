@@ -0,0 +1,223 @@
+//
+// Copyright (c) 2019 Sony Mobile Communications Inc.
+// SPDX-License-Identifier: MIT
+//
+
+// Package store keeps the tables fetched from GitHub as timestamped
+// snapshots per organization and entity, so that the history of the
+// organization can be audited and recent data reused.
+//
+// Snapshots are json dumps (see view.Json) laid out in the state directory as:
+//
+//	<state dir>/snapshots/<organization>/<entity>/<time>.json
+//
+// where time is the UTC time of the snapshot, e.g. 20210301T120000Z.
+package store
+
+import (
+	"fmt"
+	"ghorgs/gnet"
+	"ghorgs/model"
+	"ghorgs/utils"
+	"ghorgs/view"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	snapshotsDir = "snapshots"
+	timeLayout   = "20060102T150405Z"
+	extension    = "." + view.JsonFormat
+)
+
+// Snapshot identifies a stored table of an entity of an organization
+// at a point in time.
+type Snapshot struct {
+	Organization string
+	Entity       string
+	Time         time.Time
+}
+
+// Id returns the identifier of the snapshot, i.e. its path in the store
+// without extension, e.g. myorg/repos/20210301T120000Z.
+func (s Snapshot) Id() string {
+	return strings.Join([]string{s.Organization, s.Entity, s.Time.UTC().Format(timeLayout)}, "/")
+}
+
+// Path returns the file of the snapshot.
+func (s Snapshot) Path() string {
+	return filepath.Join(Dir(), snapshotsDir, filepath.FromSlash(s.Id())+extension)
+}
+
+// ParseId parses the identifier of a snapshot (see Snapshot.Id).
+func ParseId(id string) (Snapshot, error) {
+	parts := strings.Split(strings.TrimSuffix(id, extension), "/")
+	if len(parts) != 3 || parts[0] == "." || parts[0] == ".." {
+		return Snapshot{}, fmt.Errorf("Invalid snapshot `%s`. Use <organization>/<entity>/<time>.\n", id)
+	}
+
+	t, err := time.Parse(timeLayout, parts[2])
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("Invalid time of snapshot `%s`: %s\n", id, err.Error())
+	}
+	return Snapshot{parts[0], parts[1], t}, nil
+}
+
+// Dir returns the state directory of ghorgs: `state_dir` from
+// configuration, or `ghorgs` in $XDG_STATE_HOME
+// (~/.local/state/ghorgs by default).
+func Dir() string {
+	if gnet.Conf.StateDir != "" {
+		return gnet.Conf.StateDir
+	}
+
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, gnet.ConfigDir)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), gnet.ConfigDir)
+	}
+	return filepath.Join(home, ".local", "state", gnet.ConfigDir)
+}
+
+// Save stores the table of the entity of the organization as a snapshot
+// taken now.
+func Save(org, entity string, t *model.Table) (Snapshot, error) {
+	s := Snapshot{org, entity, time.Now().UTC().Truncate(time.Second)}
+	if err := os.MkdirAll(filepath.Dir(s.Path()), 0755); err != nil {
+		return s, err
+	}
+
+	w := &view.Json{FileName: s.Path(), Data: t}
+	return s, w.Flush()
+}
+
+// Load reads the table of a snapshot.
+func Load(s Snapshot) (*model.Table, error) {
+	entity, ok := model.EntityMap[s.Entity]
+	if !ok {
+		return nil, fmt.Errorf("Unknown entity of snapshot `%s`.\n", s.Id())
+	}
+	if _, err := os.Stat(s.Path()); os.IsNotExist(err) {
+		return nil, fmt.Errorf("No snapshot `%s`.\n", s.Id())
+	}
+	return view.ReadTable(s.Path(), entity)
+}
+
+// Remove removes a snapshot from the store.
+func Remove(s Snapshot) error {
+	return os.Remove(s.Path())
+}
+
+// List returns the snapshots of the organization and the entity ordered
+// by organization, entity and time (oldest first). Empty org or entity
+// selects all of them.
+func List(org, entity string) ([]Snapshot, error) {
+	root := filepath.Join(Dir(), snapshotsDir)
+	result := make([]Snapshot, 0)
+
+	orgs, err := subdirs(root, org)
+	if err != nil {
+		return result, err
+	}
+	for _, o := range orgs {
+		entities, err := subdirs(filepath.Join(root, o), entity)
+		if err != nil {
+			return result, err
+		}
+		for _, e := range entities {
+			files, err := ioutil.ReadDir(filepath.Join(root, o, e))
+			if err != nil {
+				return result, err
+			}
+			for _, f := range files {
+				if f.IsDir() || filepath.Ext(f.Name()) != extension {
+					continue
+				}
+				t, err := time.Parse(timeLayout, strings.TrimSuffix(f.Name(), extension))
+				if err != nil {
+					continue // not a snapshot
+				}
+				result = append(result, Snapshot{o, e, t})
+			}
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Organization != result[j].Organization {
+			return result[i].Organization < result[j].Organization
+		}
+		if result[i].Entity != result[j].Entity {
+			return result[i].Entity < result[j].Entity
+		}
+		return result[i].Time.Before(result[j].Time)
+	})
+
+	return result, nil
+}
+
+// Latest returns the table of the latest snapshot of the entity of
+// the organization not older than maxAge, which contains the given
+// fields (all fields of the entity if none are given), projected to them.
+// It returns nil table if there's no such snapshot.
+func Latest(org string, entity model.Entity, maxAge time.Duration, fields ...string) (*model.Table, Snapshot, error) {
+	snapshots, err := List(org, entity.GetName())
+	if err != nil {
+		return nil, Snapshot{}, err
+	}
+
+	if len(fields) == 0 {
+		fields = entity.MakeTable().FieldNames()
+	}
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		s := snapshots[i]
+		if time.Since(s.Time) > maxAge {
+			break
+		}
+		t, err := Load(s)
+		if err != nil {
+			if utils.Debug.Verbose {
+				log.Printf("Skipping snapshot `%s`: %s", s.Id(), err.Error())
+			}
+			continue
+		}
+		if t, err = t.Project(fields); err == nil {
+			return t, s, nil
+		}
+	}
+
+	return nil, Snapshot{}, nil
+}
+
+// subdirs returns the names of subdirectories of dir, or only name
+// if it's not empty and exists.
+func subdirs(dir, name string) ([]string, error) {
+	if name != "" {
+		if fi, err := os.Stat(filepath.Join(dir, name)); err != nil || !fi.IsDir() {
+			return []string{}, nil
+		}
+		return []string{name}, nil
+	}
+
+	infos, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(infos))
+	for _, fi := range infos {
+		if fi.IsDir() {
+			names = append(names, fi.Name())
+		}
+	}
+	return names, nil
+}
@@ -8,39 +8,194 @@ package utils
 import (
 	"bytes"
 	"fmt"
-	"io"
 	"net/url"
 	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
 )
 
+// GitConfiguration holds options of git commands run by ghorgs.
+type GitConfiguration struct {
+	// CaFile is a PEM bundle of certificates verifying the git server
+	// instead of the default ones, e.g. of GitHub Enterprise Server.
+	CaFile string
+	// Quiet captures the output of git commands instead of printing it,
+	// e.g. of concurrent clones. The errors of failed commands tell
+	// what git printed to stderr.
+	Quiet bool
+}
+
+var Git = GitConfiguration{}
+
+// credentials matches user and password of urls in output of git
+var credentials = regexp.MustCompile(`://[^/@\s]+@`)
+
 // GitClone clones a git from the given url into it's destination
 // at out/name.
 func GitClone(url, out, name string) error {
 	// assumes url and dest are valid
 	dest := path.Join(out, name)
-	cmd := exec.Command("git", "clone", url, dest)
+	return runCommand(gitCommand("clone", url, dest), "clone")
+}
+
+// GitMirror creates a mirror clone of the repository at url in dir,
+// or updates the mirror if dir exists, and tells whether any of its
+// refs changed. Credentials of url are not kept in the mirror.
+func GitMirror(url, dir string) (bool, error) {
+	remote, err := withoutCredentials(url)
+	if err != nil {
+		return false, err
+	}
 
-	var stdoutBuf, stderrBuf bytes.Buffer
-	cmd.Stdout = io.MultiWriter(os.Stdout, &stdoutBuf)
-	cmd.Stderr = io.MultiWriter(os.Stderr, &stderrBuf)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if err = runGit("clone", "--mirror", url, dir); err != nil {
+			return false, err
+		}
+		return true, runGit("-C", dir, "remote", "set-url", "origin", remote)
+	}
 
-	err := cmd.Run()
+	before, err := gitOutput("-C", dir, "for-each-ref", "--format=%(objectname) %(refname)")
 	if err != nil {
-		return fmt.Errorf("`git clone` failed with %s\n", err.Error())
+		return false, err
+	}
+	if err = runGit("-C", dir, "remote", "set-url", "origin", url); err != nil {
+		return false, err
+	}
+	err = runGit("-C", dir, "remote", "update", "--prune")
+	// drop the credentials even if the update failed
+	if serr := runGit("-C", dir, "remote", "set-url", "origin", remote); err == nil {
+		err = serr
 	}
+	if err != nil {
+		return false, err
+	}
+	after, err := gitOutput("-C", dir, "for-each-ref", "--format=%(objectname) %(refname)")
+	if err != nil {
+		return false, err
+	}
+
+	return before != after, nil
+}
 
+// GitBundle records all refs of the repository at dir into
+// a verified git bundle file dest.
+func GitBundle(dir, dest string) error {
+	abs, err := filepath.Abs(dest)
+	if err != nil {
+		return err
+	}
+	if err = runGit("-C", dir, "bundle", "create", abs, "--all"); err != nil {
+		return err
+	}
+	return runGit("-C", dir, "bundle", "verify", abs)
+}
+
+// runGit runs git command with the given arguments, printing its output.
+func runGit(args ...string) error {
+	return runCommand(gitCommand(args...), gitSubcommand(args))
+}
+
+// runCommand runs the git subcommand, printing its output unless
+// Git.Quiet. Credentials of urls are removed from the captured output.
+func runCommand(cmd *exec.Cmd, subcommand string) error {
+	var stderr bytes.Buffer
+	if Git.Quiet {
+		cmd.Stderr = &stderr
+	} else {
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+	}
+	if err := cmd.Run(); err != nil {
+		out := strings.TrimSpace(credentials.ReplaceAllString(stderr.String(), "://"))
+		if out == "" {
+			return fmt.Errorf("`git %s` failed with %s\n", subcommand, err.Error())
+		}
+		return fmt.Errorf("`git %s` failed with %s: %s\n", subcommand, err.Error(), out)
+	}
 	return nil
 }
 
+// gitOutput runs git command with the given arguments and returns its output.
+func gitOutput(args ...string) (string, error) {
+	out, err := gitCommand(args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("`git %s` failed with %s\n", gitSubcommand(args), err.Error())
+	}
+	return string(out), nil
+}
+
+// gitSubcommand returns the subcommand of git arguments for messages,
+// i.e. without credentials of urls.
+func gitSubcommand(args []string) string {
+	if len(args) > 2 && args[0] == "-C" {
+		args = args[2:]
+	}
+	if len(args) > 1 && args[0] == "remote" {
+		return "remote " + args[1]
+	}
+	return args[0]
+}
+
+// withoutCredentials returns the url without user and password.
+func withoutCredentials(rawurl string) (string, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return "", fmt.Errorf("Project url error. %s", err.Error())
+	}
+	u.User = nil
+	return u.String(), nil
+}
+
+// gitCommand returns git command with the given arguments, which
+// verifies servers with Git.CaFile.
+func gitCommand(args ...string) *exec.Cmd {
+	cmd := exec.Command("git", args...)
+	if Git.CaFile != "" {
+		cmd.Env = append(os.Environ(), "GIT_SSL_CAINFO="+Git.CaFile)
+	}
+	return cmd
+}
+
+// GitPush pushes refs of the repository at dir to url, given by
+// refspecs, e.g. refs/tags/*:refs/tags/*.
+func GitPush(dir, url string, refspecs ...string) error {
+	return runCommand(gitCommand(append([]string{"-C", dir, "push", url}, refspecs...)...), "push")
+}
+
+// GitRefs returns the names of refs of the repository at dir
+// matching the pattern, e.g. refs/heads.
+func GitRefs(dir, pattern string) ([]string, error) {
+	out, err := gitOutput("-C", dir, "for-each-ref", "--format=%(refname)", pattern)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(out), nil
+}
+
+// GitHead returns the ref HEAD of the repository at dir points to,
+// e.g. refs/heads/master.
+func GitHead(dir string) (string, error) {
+	out, err := gitOutput("-C", dir, "symbolic-ref", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
 // Url returns authentication url from a raw url string and
-// user credentials.
-func Url(rawurl, user, pass string) (string, error) {
+// user credentials. The credentials are given only to the host
+// they belong to, e.g. github.com or a GitHub Enterprise Server.
+func Url(rawurl, host, user, pass string) (string, error) {
 	u, err := url.Parse(rawurl)
 	if err != nil {
 		return "", fmt.Errorf("Project url (%s) error. %s", rawurl, err.Error())
 	}
+	if !strings.EqualFold(u.Host, host) {
+		return "", fmt.Errorf("Project url (%s) is not on %s.\n", rawurl, host)
+	}
 
 	if user != "" && pass != "" {
 		u.User = url.UserPassword(user, pass)
@@ -14,6 +14,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // TarGz creates a tar.gz archive from a given path.
@@ -25,7 +26,11 @@ import (
 // This is useful when unpacking the archive on an arbitrary
 // machine that doesn't necessarily have the /tmp/folder path.
 func TarGz(name, src string) error {
-	dest := src + ".tar.gz"
+	return TarGzTo(name, src, src+".tar.gz")
+}
+
+// TarGzTo creates a tar.gz archive dest from a given path like TarGz.
+func TarGzTo(name, src, dest string) error {
 	f, err := os.Create(dest)
 	if err != nil {
 		return fmt.Errorf("Could not create %s. Error! %s", dest, err.Error())
@@ -69,7 +74,9 @@ func TarGz(name, src string) error {
 				return err
 			}
 			relFilePath = filepath.Join(name, relFilePath)
-			fmt.Printf("File to add: %s", relFilePath)
+			if Debug.Verbose {
+				fmt.Printf("File to add: %s\n", relFilePath)
+			}
 		}
 		h.Name = relFilePath
 		err = tw.WriteHeader(h)
@@ -97,7 +104,11 @@ func TarGz(name, src string) error {
 // present in the src.tar.gz archive with content path relative to
 // name.
 func TargzVerify(name, src string) error {
-	dest := src + ".tar.gz"
+	return TargzVerifyOf(name, src, src+".tar.gz")
+}
+
+// TargzVerifyOf checks the tar.gz archive dest of src like TargzVerify.
+func TargzVerifyOf(name, src, dest string) error {
 	ar, err := os.Open(dest)
 	if err != nil {
 		return fmt.Errorf("Could not open '%s' for reading. Error! %s",
@@ -145,10 +156,8 @@ func TargzVerify(name, src string) error {
 				dest, err.Error())
 		}
 
-		// Validate that the file path does not contain ".."
-		if strings.Contains(h.Name, "..") {
-			return fmt.Errorf("Invalid file path '%s' in archive '%s'. Path traversal detected.",
-				h.Name, dest)
+		if err = validTarPath(h.Name, dest); err != nil {
+			return err
 		}
 
 		fi, ok := fimap[h.Name]
@@ -184,6 +193,120 @@ func TargzVerify(name, src string) error {
 	return nil
 }
 
+// TarGzExtract extracts a tar.gz archive created by TarGz into dest
+// folder. Entries escaping dest (see TargzVerify), also through symlinks
+// extracted before, and other entries than folders, regular files and
+// symlinks within the archive are rejected.
+func TarGzExtract(archive, dest string) error {
+	ar, err := os.Open(archive)
+	if err != nil {
+		return fmt.Errorf("Could not open '%s' for reading. Error! %s",
+			archive, err.Error())
+	}
+	defer ar.Close()
+
+	gr, err := gzip.NewReader(ar)
+	if err != nil {
+		return fmt.Errorf("Could not uncompress archive '%s'. Error! %s",
+			archive, err.Error())
+	}
+	// symlinks extracted before may point anywhere within dest, so the
+	// folders of entries are checked where they resolve to
+	root, err := filepath.EvalSymlinks(dest)
+	if err != nil {
+		return fmt.Errorf("Could not resolve '%s'. Error! %s", dest, err.Error())
+	}
+	tr := tar.NewReader(gr)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("Header check error for archive '%s'. Error! %s",
+				archive, err.Error())
+		}
+		if err = validTarPath(h.Name, archive); err != nil {
+			return err
+		}
+
+		target := filepath.Join(root, filepath.FromSlash(h.Name))
+		switch h.Typeflag {
+		case tar.TypeDir:
+			err = mkdirWithin(root, target)
+		case tar.TypeReg:
+			err = extractFile(tr, root, target, h.FileInfo().Mode())
+		case tar.TypeSymlink:
+			// the link must stay within the archive too
+			link := filepath.ToSlash(filepath.Join(filepath.Dir(h.Name), h.Linkname))
+			if filepath.IsAbs(h.Linkname) || validTarPath(link, archive) != nil {
+				return fmt.Errorf("Invalid symlink '%s' -> '%s' in archive '%s'. Path traversal detected.",
+					h.Name, h.Linkname, archive)
+			}
+			if err = mkdirWithin(root, filepath.Dir(target)); err == nil {
+				err = os.Symlink(h.Linkname, target)
+			}
+		default:
+			return fmt.Errorf("Unsupported entry '%s' in archive '%s'.", h.Name, archive)
+		}
+		if err != nil {
+			return fmt.Errorf("Could not extract '%s' from archive '%s'. Error! %s",
+				h.Name, archive, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// validTarPath checks that the path of an entry of archive stays
+// within the folder the archive is extracted to.
+func validTarPath(name, archive string) error {
+	// Validate that the file path does not contain ".."
+	if strings.Contains(name, "..") || filepath.IsAbs(name) || strings.HasPrefix(name, "/") {
+		return fmt.Errorf("Invalid file path '%s' in archive '%s'. Path traversal detected.",
+			name, archive)
+	}
+	return nil
+}
+
+// extractFile writes the content of the current entry of tr to a new
+// file at target, creating the parent folders within root.
+func extractFile(tr *tar.Reader, root, target string, mode os.FileMode) error {
+	if err := mkdirWithin(root, filepath.Dir(target)); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode.Perm())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(f, tr)
+	return err
+}
+
+// mkdirWithin creates the folder dir and its parents, unless the
+// existing part of its path resolves outside of root through symlinks.
+func mkdirWithin(root, dir string) error {
+	existing := dir
+	for {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			if rel, err := filepath.Rel(root, resolved); err != nil ||
+				rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				return fmt.Errorf("'%s' resolves outside of '%s'. Path traversal detected.",
+					dir, root)
+			}
+			break
+		}
+		if !os.IsNotExist(err) || existing == root {
+			return err
+		}
+		existing = filepath.Dir(existing)
+	}
+	return os.MkdirAll(dir, 0755)
+}
+
 func keysOf(m map[string]os.FileInfo) []string {
 	keys := make([]string, 0, len(m))
 	for key := range m {
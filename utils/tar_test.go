@@ -0,0 +1,143 @@
+//
+// Copyright (c) 2019 Sony Mobile Communications Inc.
+// SPDX-License-Identifier: MIT
+//
+
+package utils
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// entry is a file (with content), folder (name ending with `/`)
+// or symlink (with link) of a test archive.
+type entry struct {
+	name    string
+	content string
+	link    string
+}
+
+func writeTarGz(t *testing.T, archive string, entries []entry) {
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gw := gzip.NewWriter(f)
+	defer gw.Close()
+	tw := tar.NewWriter(gw)
+	defer tw.Close()
+
+	for _, e := range entries {
+		h := &tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.content))}
+		switch {
+		case e.link != "":
+			h.Typeflag, h.Linkname, h.Size = tar.TypeSymlink, e.link, 0
+		case strings.HasSuffix(e.name, "/"):
+			h.Typeflag, h.Mode = tar.TypeDir, 0755
+		default:
+			h.Typeflag = tar.TypeReg
+		}
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestTarGzExtract(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "ghorgs-tar-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	src := filepath.Join(tmp, "src")
+	if err = os.MkdirAll(filepath.Join(src, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(src, "sub", "f"), []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Symlink("sub/f", filepath.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+	archive := filepath.Join(tmp, "repo.tar.gz")
+	if err = TarGzTo("repo", src, archive); err != nil {
+		t.Fatal(err)
+	}
+
+	dest := filepath.Join(tmp, "dest")
+	if err = os.Mkdir(dest, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = TarGzExtract(archive, dest); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dest, "repo", "link"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "content" {
+		t.Errorf("repo/link has %q, want %q", data, "content")
+	}
+}
+
+func TestTarGzExtractTraversal(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []entry
+	}{
+		{"dot-dot", []entry{{name: "../f", content: "evil"}}},
+		{"dot-dot within", []entry{{name: "d/../../f", content: "evil"}}},
+		{"absolute", []entry{{name: "/tmp/f", content: "evil"}}},
+		{"absolute symlink", []entry{{name: "up", link: "/"}, {name: "up/f", content: "evil"}}},
+		{"dot-dot symlink", []entry{{name: "up", link: ".."}, {name: "up/f", content: "evil"}}},
+		// each link stays within the archive by its name, but the
+		// second one is created through the first one
+		{"chained symlinks", []entry{
+			{name: "d/"},
+			{name: "d/up", link: ".."},
+			{name: "d/up/up", link: ".."},
+			{name: "up/sub/f", content: "evil"},
+		}},
+		{"chained symlinks folder", []entry{
+			{name: "d/"},
+			{name: "d/up", link: ".."},
+			{name: "d/up/up", link: ".."},
+			{name: "up/sub/"},
+		}},
+	}
+
+	for _, test := range tests {
+		tmp, err := ioutil.TempDir("", "ghorgs-tar-")
+		if err != nil {
+			t.Fatal(err)
+		}
+		archive := filepath.Join(tmp, "evil.tar.gz")
+		writeTarGz(t, archive, test.entries)
+		dest := filepath.Join(tmp, "a", "dest")
+		if err = os.MkdirAll(dest, 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = TarGzExtract(archive, dest); err == nil {
+			t.Errorf("%s: TarGzExtract didn't fail", test.name)
+		}
+		for _, outside := range []string{filepath.Join(tmp, "f"), filepath.Join(tmp, "a", "f"),
+			filepath.Join(tmp, "a", "sub"), "/tmp/f"} {
+			if _, err := os.Lstat(outside); err == nil {
+				t.Errorf("%s: %s was written outside of %s", test.name, outside, dest)
+			}
+		}
+		os.RemoveAll(tmp)
+	}
+}
@@ -6,70 +6,66 @@
 package view
 
 import (
+	"bufio"
+	"encoding/csv"
 	"ghorgs/model"
-	"os"
-	"reflect"
 )
 
+// utf8Bom marks the file as UTF-8 encoded for spreadsheet applications
+// (e.g. Excel), which otherwise assume a legacy encoding.
+const utf8Bom = "\uFEFF"
+
+// Csv writes a table in RFC 4180 format: a header line with field names
+// followed by a line per record, with cells quoted where needed and empty
+// cells left empty.
 type Csv struct {
-	FileName string
-	Data     *model.Table
+	FileName  string
+	Data      *model.Table
+	Delimiter rune // ',' if not set
+	Bom       bool // start the file with UTF-8 byte order mark
 }
 
 func MakeCsv(filename string) *Csv {
 	data := model.MakeTable([]model.Field{})
-	return &Csv{filename, data}
+	return &Csv{FileName: filename, Data: data}
 }
 
 func (c *Csv) Log() {
 	c.Data.Log()
 }
 
-func (c *Csv) Flush() {
-	var f *os.File
+func (c *Csv) Flush() error {
+	f, err := create(c.FileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
 
-	if _, err := os.Stat(c.FileName); err == nil || os.IsNotExist(err) {
-		f, err = os.OpenFile(c.FileName,
-			os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
-		if err != nil {
-			panic(err)
+	bw := bufio.NewWriter(f)
+	if c.Bom {
+		if _, err = bw.WriteString(utf8Bom); err != nil {
+			return err
 		}
-		defer f.Close()
-	} else {
-		panic(err)
 	}
 
-	title := c.Data.FieldNames()
-	s := lineToString(title)
-	if _, err := f.WriteString(s + "\n"); err != nil {
-		panic(err)
+	w := csv.NewWriter(bw)
+	if c.Delimiter != 0 {
+		w.Comma = c.Delimiter
 	}
+	w.UseCRLF = true
 
+	if err = w.Write(c.Data.FieldNames()); err != nil {
+		return err
+	}
 	for _, k := range c.Data.Keys {
-		// if by any chance we've loaded a dump from disk
-		// and it includes title line, just skip it now
-		if k == title[0] && reflect.DeepEqual(c.Data.Records[k], title[1:]) {
-			continue
-		}
-
-		s = k + "\t" + lineToString(c.Data.Records[k])
-		if _, err := f.WriteString(s + "\n"); err != nil {
-			panic(err)
+		if err = w.Write(append([]string{k}, c.Data.Records[k]...)); err != nil {
+			return err
 		}
 	}
-}
 
-func lineToString(line []string) string {
-	s := ""
-	for i, cell := range line {
-		if len(cell) == 0 {
-			cell = "-"
-		}
-		s += cell
-		if i < len(line)-1 {
-			s += "\t"
-		}
+	w.Flush()
+	if err = w.Error(); err != nil {
+		return err
 	}
-
-	return s
+	return bw.Flush()
 }
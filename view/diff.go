@@ -0,0 +1,185 @@
+//
+// Copyright (c) 2019 Sony Mobile Communications Inc.
+// SPDX-License-Identifier: MIT
+//
+
+package view
+
+import (
+	"bytes"
+	"fmt"
+	"ghorgs/model"
+	"io"
+	"strings"
+)
+
+const (
+	TextFormat     = "text"
+	MarkdownFormat = "markdown"
+)
+
+// DiffFormats lists the formats supported by WriteDiff.
+var DiffFormats = []string{TextFormat, JsonFormat, MarkdownFormat}
+
+// WriteDiff writes the difference d between two tables of the entity
+// named name to w in a given format.
+func WriteDiff(w io.Writer, format, name string, d *model.TableDiff) error {
+	switch format {
+	case TextFormat:
+		return writeDiffText(w, name, d)
+	case JsonFormat:
+		return writeDiffJson(w, name, d)
+	case MarkdownFormat:
+		return writeDiffMarkdown(w, name, d)
+	}
+
+	return fmt.Errorf("Unknown format `%s`. Choose one of: %s.\n",
+		format, strings.Join(DiffFormats, ", "))
+}
+
+func summary(d *model.TableDiff) string {
+	return fmt.Sprintf("%d added, %d removed, %d changed",
+		len(d.Added.Keys), len(d.Removed.Keys), len(d.Changed))
+}
+
+// writeDiffText writes a line per added (+), removed (-) and changed (~)
+// record, followed by the changed fields of the record.
+func writeDiffText(w io.Writer, name string, d *model.TableDiff) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s: %s\n", name, summary(d))
+	for _, key := range d.Added.Keys {
+		fmt.Fprintf(&b, "+ %s\n", recordLabel(d.Added, key))
+	}
+	for _, key := range d.Removed.Keys {
+		fmt.Fprintf(&b, "- %s\n", recordLabel(d.Removed, key))
+	}
+	for _, rc := range d.Changed {
+		fmt.Fprintf(&b, "~ %s\n", labelOf(rc.Label, rc.Key))
+		for _, fc := range rc.Changes {
+			fmt.Fprintf(&b, "    %s: %s -> %s\n", fc.Field.Name, textCell(fc.Before), textCell(fc.After))
+		}
+	}
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func recordLabel(t *model.Table, key string) string {
+	if len(t.Fields) == 0 {
+		return key
+	}
+	return labelOf(t.Records[key][0], key)
+}
+
+func labelOf(label, key string) string {
+	if label == "" {
+		return key
+	}
+	return label + " (" + key + ")"
+}
+
+func textCell(cell string) string {
+	if cell == "" {
+		return "-"
+	}
+	return strings.NewReplacer("\r", `\r`, "\n", `\n`).Replace(cell)
+}
+
+// writeDiffJson writes an object with the entity's name, added and
+// removed records (as written by Json) and changed records with
+// typed before and after values of the changed fields.
+func writeDiffJson(w io.Writer, name string, d *model.TableDiff) error {
+	var b bytes.Buffer
+	b.WriteString("{")
+	writeMember(&b, "entity", name)
+	b.WriteString(",\n\"added\":")
+	writeRecords(&b, d.Added)
+	b.WriteString(",\n\"removed\":")
+	writeRecords(&b, d.Removed)
+	b.WriteString(",\n\"changed\":[")
+	for i, rc := range d.Changed {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n  {")
+		writeMember(&b, model.ID.Name, rc.Key)
+		b.WriteString(",")
+		writeMember(&b, "label", rc.Label)
+		b.WriteString(",\"changes\":[")
+		for j, fc := range rc.Changes {
+			if j > 0 {
+				b.WriteString(",")
+			}
+			b.WriteString("{")
+			writeMember(&b, "field", fc.Field.Name)
+			b.WriteString(",")
+			writeMember(&b, "before", typedValue(fc.Field, fc.Before))
+			b.WriteString(",")
+			writeMember(&b, "after", typedValue(fc.Field, fc.After))
+			b.WriteString("}")
+		}
+		b.WriteString("]}")
+	}
+	b.WriteString("\n]}\n")
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func writeRecords(b *bytes.Buffer, t *model.Table) {
+	b.WriteString("[")
+	for i, key := range t.Keys {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n  ")
+		writeRecord(b, t, key) // writing to bytes.Buffer doesn't fail
+	}
+	b.WriteString("\n]")
+}
+
+// writeDiffMarkdown writes sections with tables of added, removed and
+// changed records.
+func writeDiffMarkdown(w io.Writer, name string, d *model.TableDiff) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "## %s\n\n%s\n", name, summary(d))
+
+	for _, section := range []struct {
+		title string
+		t     *model.Table
+	}{{"Added", d.Added}, {"Removed", d.Removed}} {
+		if len(section.t.Keys) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n### %s\n\n", section.title)
+		header := section.t.FieldNames()
+		markdownRow(&b, header)
+		b.WriteString(strings.Repeat("|---", len(header)) + "|\n")
+		for _, key := range section.t.Keys {
+			markdownRow(&b, append([]string{key}, section.t.Records[key]...))
+		}
+	}
+
+	if len(d.Changed) > 0 {
+		b.WriteString("\n### Changed\n\n")
+		markdownRow(&b, []string{model.ID.Name, "Record", "Field", "Before", "After"})
+		b.WriteString(strings.Repeat("|---", 5) + "|\n")
+		for _, rc := range d.Changed {
+			for _, fc := range rc.Changes {
+				markdownRow(&b, []string{rc.Key, rc.Label, fc.Field.Name, fc.Before, fc.After})
+			}
+		}
+	}
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+var markdownEscaper = strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>")
+
+func markdownRow(b *bytes.Buffer, cells []string) {
+	for _, cell := range cells {
+		b.WriteString("| " + markdownEscaper.Replace(cell) + " ")
+	}
+	b.WriteString("|\n")
+}
@@ -0,0 +1,124 @@
+//
+// Copyright (c) 2019 Sony Mobile Communications Inc.
+// SPDX-License-Identifier: MIT
+//
+
+package view
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"ghorgs/model"
+	"io"
+	"strconv"
+)
+
+// Json writes a table as a json array of objects keyed by
+// the table's field names.
+type Json struct {
+	FileName string
+	Data     *model.Table
+}
+
+// Ndjson writes a table as newline delimited json objects
+// (one object per record) keyed by the table's field names.
+type Ndjson struct {
+	FileName string
+	Data     *model.Table
+}
+
+func (j *Json) Flush() error {
+	f, err := create(j.FileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	if _, err = w.WriteString("["); err != nil {
+		return err
+	}
+	for i, key := range j.Data.Keys {
+		sep := ",\n  "
+		if i == 0 {
+			sep = "\n  "
+		}
+		if _, err = w.WriteString(sep); err != nil {
+			return err
+		}
+		if err = writeRecord(w, j.Data, key); err != nil {
+			return err
+		}
+	}
+	if _, err = w.WriteString("\n]\n"); err != nil {
+		return err
+	}
+
+	return w.Flush()
+}
+
+func (n *Ndjson) Flush() error {
+	f, err := create(n.FileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	for _, key := range n.Data.Keys {
+		if err = writeRecord(w, n.Data, key); err != nil {
+			return err
+		}
+		if _, err = w.WriteString("\n"); err != nil {
+			return err
+		}
+	}
+
+	return w.Flush()
+}
+
+// writeRecord writes the record with a given key as a json object
+// with fields in the order of the table's fields.
+func writeRecord(w io.Writer, t *model.Table, key string) error {
+	var b bytes.Buffer
+	b.WriteString("{")
+	writeMember(&b, model.ID.Name, key)
+	for i, field := range t.Fields {
+		b.WriteString(",")
+		writeMember(&b, field.Name, typedValue(field, t.Records[key][i]))
+	}
+	b.WriteString("}")
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func writeMember(b *bytes.Buffer, name string, v interface{}) {
+	k, _ := json.Marshal(name)
+	val, _ := json.Marshal(v)
+	b.Write(k)
+	b.WriteString(":")
+	b.Write(val)
+}
+
+// typedValue converts a cell to a value of the field's type:
+// a number, a boolean or a string, and an empty cell to null.
+func typedValue(field model.Field, cell string) interface{} {
+	if cell == "" {
+		return nil
+	}
+
+	switch field.Type {
+	case model.IntType:
+		if n, err := strconv.ParseInt(cell, 10, 64); err == nil {
+			return n
+		}
+	case model.BoolType:
+		if b, err := strconv.ParseBool(cell); err == nil {
+			return b
+		}
+	}
+
+	return cell
+}
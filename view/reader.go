@@ -0,0 +1,196 @@
+//
+// Copyright (c) 2019 Sony Mobile Communications Inc.
+// SPDX-License-Identifier: MIT
+//
+
+package view
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"ghorgs/model"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ReadTable reads a file written by a Writer back into a table of
+// a given entity. The format is given by the file's extension and the
+// fields by the file's header (csv and tsv) or object keys (json and ndjson),
+// which have to be fields of the entity. The first column must be `Id`.
+func ReadTable(filename string, entity model.Entity) (*model.Table, error) {
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	format := strings.TrimPrefix(filepath.Ext(filename), ".")
+	var rows [][]string
+	switch format {
+	case CsvFormat:
+		rows, err = readCsv(buf)
+	case TsvFormat:
+		rows, err = readTsv(buf)
+	case JsonFormat, NdjsonFormat:
+		rows, err = readJson(buf, format == JsonFormat)
+	default:
+		return nil, fmt.Errorf("Unknown format of `%s`. Choose one of: %s.\n",
+			filename, strings.Join(Formats, ", "))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s file `%s`: %s\n", format, filename, err.Error())
+	}
+
+	// empty json has no keys to take the fields from
+	if len(rows) == 0 && (format == JsonFormat || format == NdjsonFormat) {
+		return entity.MakeTable(), nil
+	}
+	if len(rows) == 0 || len(rows[0]) == 0 || rows[0][0] != model.ID.Name {
+		return nil, fmt.Errorf("Invalid %s file `%s`: the first column must be `%s`.\n",
+			format, filename, model.ID.Name)
+	}
+
+	t, err := entity.MakeTable().Project(rows[0][1:])
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s file `%s` of %s: %s",
+			format, filename, entity.GetName(), err.Error())
+	}
+	for _, row := range rows[1:] {
+		t.AddKey(row[0])
+		t.AddRecord(row[0], row[1:])
+	}
+
+	return t, nil
+}
+
+// readCsv reads RFC 4180 csv with the delimiter following the `Id`
+// column of the header (`,` if `Id` is the only one), skipping the byte
+// order mark if any.
+func readCsv(buf []byte) ([][]string, error) {
+	buf = bytes.TrimPrefix(buf, []byte(utf8Bom))
+
+	r := csv.NewReader(bytes.NewReader(buf))
+	header := strings.TrimPrefix(string(buf), `"`)
+	if strings.HasPrefix(header, model.ID.Name) && len(header) > len(model.ID.Name) {
+		header = strings.TrimPrefix(header[len(model.ID.Name):], `"`)
+		if header != "" && header[0] != '\r' && header[0] != '\n' {
+			r.Comma = []rune(header)[0]
+		}
+	}
+
+	return r.ReadAll()
+}
+
+// readTsv reads tab separated lines with `-` in place of empty cells.
+func readTsv(buf []byte) ([][]string, error) {
+	rows := make([][]string, 0)
+	s := bufio.NewScanner(bytes.NewReader(buf))
+	s.Buffer(make([]byte, 0, 64*1024), len(buf)+1)
+	for s.Scan() {
+		if s.Text() == "" {
+			continue
+		}
+		row := strings.Split(s.Text(), "\t")
+		for i, cell := range row {
+			if cell == "-" {
+				row[i] = ""
+			}
+		}
+		if len(rows) > 0 && len(row) != len(rows[0]) {
+			return nil, fmt.Errorf("line %d has %d cells instead of %d",
+				len(rows)+1, len(row), len(rows[0]))
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, s.Err()
+}
+
+// readJson reads a json array of objects (or newline delimited objects if
+// array is false) with the keys in the same order in all the objects.
+func readJson(buf []byte, array bool) ([][]string, error) {
+	d := json.NewDecoder(bytes.NewReader(buf))
+	d.UseNumber()
+	if array {
+		if err := expectDelim(d, '['); err != nil {
+			return nil, err
+		}
+	}
+
+	rows := make([][]string, 0)
+	for d.More() {
+		keys, values, err := readObject(d)
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) == 0 {
+			rows = append(rows, keys)
+		} else if strings.Join(keys, "\n") != strings.Join(rows[0], "\n") {
+			return nil, fmt.Errorf("object %d has different keys than the first one", len(rows))
+		}
+		rows = append(rows, values)
+	}
+
+	if array {
+		if err := expectDelim(d, ']'); err != nil {
+			return nil, err
+		}
+	}
+
+	return rows, nil
+}
+
+// readObject reads a flat json object and returns its keys in order of
+// appearance and its values converted back to cells (see typedValue).
+func readObject(d *json.Decoder) ([]string, []string, error) {
+	if err := expectDelim(d, '{'); err != nil {
+		return nil, nil, err
+	}
+
+	keys := make([]string, 0)
+	values := make([]string, 0)
+	for d.More() {
+		t, err := d.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		keys = append(keys, t.(string))
+
+		if t, err = d.Token(); err != nil {
+			return nil, nil, err
+		}
+		switch v := t.(type) {
+		case nil:
+			values = append(values, "")
+		case string:
+			values = append(values, v)
+		case json.Number:
+			values = append(values, v.String())
+		case bool:
+			values = append(values, strconv.FormatBool(v))
+		default:
+			return nil, nil, fmt.Errorf("unexpected `%v` as value of `%s`", v, keys[len(keys)-1])
+		}
+	}
+
+	return keys, values, expectDelim(d, '}')
+}
+
+func expectDelim(d *json.Decoder, delim json.Delim) error {
+	t, err := d.Token()
+	if err == io.EOF {
+		return fmt.Errorf("unexpected end of file, expected `%s`", delim)
+	}
+	if err != nil {
+		return err
+	}
+	if t != delim {
+		return fmt.Errorf("unexpected `%v`, expected `%s`", t, delim)
+	}
+	return nil
+}
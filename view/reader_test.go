@@ -0,0 +1,153 @@
+//
+// Copyright (c) 2019 Sony Mobile Communications Inc.
+// SPDX-License-Identifier: MIT
+//
+
+package view
+
+import (
+	"ghorgs/model"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// reposTable returns a table of repositories with cells needing quoting
+// or escaping in some formats (quotes, delimiters, non-ASCII), and empty
+// cells of all types.
+func reposTable() *model.Table {
+	f := model.Repos.GetFields().(*model.RepositoryFields)
+	t := model.Repos.MakeTable()
+	for _, r := range []struct {
+		id    string
+		cells map[int]string
+	}{
+		{"MDEwOlJlcG9zaXRvcnkx", map[int]string{
+			f.Name.Index:      "alpha",
+			f.Type.Index:      "PUBLIC",
+			f.Url.Index:       "https://github.com/o/alpha",
+			f.DiskUsage.Index: "100",
+			f.Updated.Index:   "2020-01-01T00:00:00Z",
+			f.LastPush.Index:  "2019-06-01T00:00:00Z",
+			f.Archived.Index:  "false",
+		}},
+		{"MDEwOlJlcG9zaXRvcnky", map[int]string{
+			f.Name.Index:      `say "hi", world; ok`,
+			f.Type.Index:      "PRIVATE",
+			f.Url.Index:       "https://github.com/o/b",
+			f.DiskUsage.Index: "2000",
+			f.Updated.Index:   "2021-05-01T00:00:00Z",
+			f.Archived.Index:  "true",
+		}},
+		{"MDEwOlJlcG9zaXRvcnkz", map[int]string{
+			f.Name.Index: "ünïcödé",
+			f.Type.Index: "PRIVATE",
+		}},
+	} {
+		record := make([]string, len(t.Fields))
+		for index, value := range r.cells {
+			record[index] = value
+		}
+		record[f.Organization.Index] = "o"
+		t.AddKey(r.id)
+		t.AddRecord(r.id, record)
+	}
+	return t
+}
+
+func TestReadTableRoundTrip(t *testing.T) {
+	full := reposTable()
+	projected, err := full.Project([]string{"Id", "Archived", "Name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// `Id` column only
+	ids, err := full.Project([]string{"Id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "ghorgs-view-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tables := map[string]*model.Table{"full": full, "projected": projected, "ids": ids}
+	options := map[string]Options{"": {}, "semicolon": {Delimiter: ';', Bom: true}}
+	for _, format := range Formats {
+		for tname, data := range tables {
+			for oname, opts := range options {
+				if oname != "" && format != CsvFormat {
+					continue
+				}
+				name := format + "/" + tname + "/" + oname
+				basename := filepath.Join(dir, tname+oname)
+
+				w, err := MakeWriter(format, basename, data, opts)
+				if err != nil {
+					t.Fatalf("%s: MakeWriter failed: %s", name, err.Error())
+				}
+				if err = w.Flush(); err != nil {
+					t.Fatalf("%s: Flush failed: %s", name, err.Error())
+				}
+
+				read, err := ReadTable(basename+"."+format, model.Repos)
+				if err != nil {
+					t.Errorf("%s: ReadTable failed: %s", name, err.Error())
+					continue
+				}
+				if !reflect.DeepEqual(fieldNames(read), fieldNames(data)) {
+					t.Errorf("%s: fields %v, want %v", name, fieldNames(read), fieldNames(data))
+				}
+				if !reflect.DeepEqual(read.Keys, data.Keys) {
+					t.Errorf("%s: keys %v, want %v", name, read.Keys, data.Keys)
+				}
+				for _, key := range data.Keys {
+					if !reflect.DeepEqual(read.Records[key], data.Records[key]) {
+						t.Errorf("%s: record %s is %q, want %q", name, key, read.Records[key], data.Records[key])
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestReadTableErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ghorgs-view-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		file    string
+		content string
+	}{
+		{"unknown.xml", "<repos/>"},
+		{"noid.csv", "Name,Type\r\nalpha,PUBLIC\r\n"},
+		{"unknownfield.csv", "Id,Size\r\nid1,3\r\n"},
+		{"cells.tsv", "Id\tName\nid1\talpha\textra\n"},
+		{"keys.ndjson", "{\"Id\":\"id1\",\"Name\":\"a\"}\n{\"Id\":\"id2\",\"Type\":\"b\"}\n"},
+		{"array.json", "{\"Id\":\"id1\"}"},
+	}
+	for _, test := range tests {
+		file := filepath.Join(dir, test.file)
+		if err := ioutil.WriteFile(file, []byte(test.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadTable(file, model.Repos); err == nil {
+			t.Errorf("ReadTable(%s) didn't fail", test.file)
+		}
+	}
+}
+
+func fieldNames(t *model.Table) []string {
+	names := make([]string, 0, len(t.Fields))
+	for _, f := range t.Fields {
+		names = append(names, f.Name)
+	}
+	return names
+}
@@ -0,0 +1,67 @@
+//
+// Copyright (c) 2019 Sony Mobile Communications Inc.
+// SPDX-License-Identifier: MIT
+//
+
+package view
+
+import (
+	"ghorgs/model"
+	"reflect"
+)
+
+// Tsv writes a table as tab separated lines without quoting,
+// with `-` in place of empty cells.
+type Tsv struct {
+	FileName string
+	Data     *model.Table
+}
+
+func (c *Tsv) Log() {
+	c.Data.Log()
+}
+
+func (c *Tsv) Flush() error {
+	f, err := create(c.FileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	title := c.Data.FieldNames()
+	s := lineToString(title)
+	if _, err := f.WriteString(s + "\n"); err != nil {
+		return err
+	}
+
+	for _, k := range c.Data.Keys {
+		// if by any chance we've loaded a dump from disk
+		// and it includes title line, just skip it now
+		if k == title[0] && reflect.DeepEqual(c.Data.Records[k], title[1:]) {
+			continue
+		}
+
+		// no tab after the key of records without other fields
+		s = lineToString(append([]string{k}, c.Data.Records[k]...))
+		if _, err := f.WriteString(s + "\n"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func lineToString(line []string) string {
+	s := ""
+	for i, cell := range line {
+		if len(cell) == 0 {
+			cell = "-"
+		}
+		s += cell
+		if i < len(line)-1 {
+			s += "\t"
+		}
+	}
+
+	return s
+}
@@ -0,0 +1,62 @@
+//
+// Copyright (c) 2019 Sony Mobile Communications Inc.
+// SPDX-License-Identifier: MIT
+//
+
+package view
+
+import (
+	"fmt"
+	"ghorgs/model"
+	"os"
+	"strings"
+)
+
+// Writer writes a Table to a file in some format.
+type Writer interface {
+	Flush() error
+}
+
+const (
+	CsvFormat    = "csv"
+	TsvFormat    = "tsv"
+	JsonFormat   = "json"
+	NdjsonFormat = "ndjson"
+)
+
+// Formats lists the output formats supported by MakeWriter.
+var Formats = []string{CsvFormat, TsvFormat, JsonFormat, NdjsonFormat}
+
+// Options holds settings of writers which apply only to some formats.
+type Options struct {
+	Delimiter rune // csv
+	Bom       bool // csv
+}
+
+// MakeWriter creates a Writer of data in a given format to a file
+// named after basename with the format's extension, e.g. repos.json
+// for basename "repos" and "json" format.
+func MakeWriter(format, basename string, data *model.Table, opts Options) (Writer, error) {
+	filename := basename + "." + format
+	switch format {
+	case CsvFormat:
+		return &Csv{FileName: filename,
+			Data:      data,
+			Delimiter: opts.Delimiter,
+			Bom:       opts.Bom}, nil
+	case TsvFormat:
+		return &Tsv{FileName: filename, Data: data}, nil
+	case JsonFormat:
+		return &Json{FileName: filename, Data: data}, nil
+	case NdjsonFormat:
+		return &Ndjson{FileName: filename, Data: data}, nil
+	}
+
+	return nil, fmt.Errorf("Unknown format `%s`. Choose one of: %s.\n",
+		format, strings.Join(Formats, ", "))
+}
+
+// create creates (or truncates) a file for writing.
+func create(filename string) (*os.File, error) {
+	return os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+}